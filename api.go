@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+// apiServer serves the stats and the tube operations as JSON
+//
+//	GET  /stats                      server stats
+//	GET  /tubes                      stats of every tube
+//	GET  /tubes/{name}               stats of the tube
+//	GET  /tubes/{name}/peek/{state}  next ready, delayed or buried job, the body is base64 encoded
+//	POST /tubes/{name}/kick?n=100    kick up to n jobs, every buried job without n, or every delayed job
+//	                                 when none is buried
+//	POST /tubes/{name}/bury          bury the ready jobs
+//	POST /tubes/{name}/delete?state=buried
+//	POST /tubes/{name}/pause?for=5m
+//	GET  /columns                    columns of the system and tube grids, the dashboard mirrors them
+//	GET  /events                     polled samples as server-sent events
+//	GET  /                           dashboard
+//
+// The operations require the X-Beanwalker header and are refused from other origins.
+type apiServer struct {
+	// the connection serves a single request at a time, the tube in use is connection state
+	client *walker.Client
+	lock   sync.Mutex
+	ln     net.Listener
+	srv    *http.Server
+	hub    *sampleHub
+}
+
+// apiHeader must be sent along with the operations, browsers send custom headers to other origins only after
+// a preflight request the API never allows, so other sites cannot run the operations
+const apiHeader = "X-Beanwalker"
+
+// apiResult is the outcome of a tube operation
+type apiResult struct {
+	Tube      string       `json:"tube"`
+	Operation string       `json:"operation"`
+	State     walker.State `json:"state,omitempty"`
+	Count     int          `json:"count"`
+	IDs       []uint64     `json:"ids,omitempty"`
+	Message   string       `json:"message"`
+}
+
+// httpError is an error along with the HTTP status it is reported with
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &httpError{http.StatusBadRequest, fmt.Errorf(format, args...)}
+}
+
+// listenAPI listens on the address, the API is served over its own connection to beanstalkd
+func listenAPI(addr string, dialer *walker.Dialer, beanstalkAddr string) (*apiServer, error) {
+	c, err := dialer.Dial(beanstalkAddr)
+	if err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	s := &apiServer{client: c, ln: ln, hub: newSampleHub()}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", s.handle(s.stats))
+	mux.HandleFunc("/tubes", s.handle(s.tubes))
+	mux.HandleFunc("/tubes/", s.handle(s.tube))
+	mux.HandleFunc("/columns", s.handle(s.columns))
+	mux.HandleFunc("/events", s.events)
+	mux.HandleFunc("/", s.dashboard)
+	s.srv = &http.Server{Handler: mux}
+
+	return s, nil
+}
+
+func (s *apiServer) Serve() error {
+	return s.srv.Serve(s.ln)
+}
+
+// poll publishes the samples polled at the interval, for the dashboard served without the user interface
+func (s *apiServer) poll(interval time.Duration) {
+	for {
+		s.lock.Lock()
+		sample, err := s.client.Sample()
+		s.lock.Unlock()
+		if err == nil {
+			s.hub.publish(sample)
+		}
+		<-time.After(interval)
+	}
+}
+
+// statsJSON returns the stats keyed by name, durations are given in seconds as beanstalkd does
+func statsJSON(v interface{}) map[string]interface{} {
+	m := walker.FieldMap(v)
+	for k, value := range m {
+		if d, ok := value.(time.Duration); ok {
+			m[k] = int64(d / time.Second)
+		}
+	}
+	return m
+}
+
+// handle serializes the requests and writes the value or the error as JSON
+func (s *apiServer) handle(fn func(r *http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.lock.Lock()
+		v, err := fn(r)
+		s.lock.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			v = map[string]string{"error": err.Error()}
+		}
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+// errorStatus returns the HTTP status the error is reported with
+func errorStatus(err error) int {
+	switch {
+	case walker.IsNotFound(err):
+		return http.StatusNotFound
+	case err == walker.ErrDraining:
+		return http.StatusConflict
+	}
+	if e, ok := err.(*httpError); ok {
+		return e.status
+	}
+	return http.StatusInternalServerError
+}
+
+func allowMethod(r *http.Request, method string) error {
+	if r.Method != method {
+		return &httpError{http.StatusMethodNotAllowed, fmt.Errorf("%s requires %s", r.URL.Path, method)}
+	}
+	return nil
+}
+
+// allowOperation rejects the operations sent without the API header or from another origin
+func allowOperation(r *http.Request) error {
+	if r.Header.Get(apiHeader) == "" {
+		return &httpError{http.StatusForbidden, fmt.Errorf("%s requires the %s header", r.URL.Path, apiHeader)}
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+			return &httpError{http.StatusForbidden, fmt.Errorf("%s: requests from %s are not allowed", r.URL.Path, origin)}
+		}
+	}
+	return nil
+}
+
+func (s *apiServer) stats(r *http.Request) (interface{}, error) {
+	if err := allowMethod(r, http.MethodGet); err != nil {
+		return nil, err
+	}
+	stats, err := s.client.ServerStats()
+	if err != nil {
+		return nil, err
+	}
+	return statsJSON(stats), nil
+}
+
+func (s *apiServer) tubes(r *http.Request) (interface{}, error) {
+	if err := allowMethod(r, http.MethodGet); err != nil {
+		return nil, err
+	}
+	all, err := s.client.AllTubeStats()
+	if err != nil {
+		return nil, err
+	}
+	tubes := []map[string]interface{}{}
+	for _, stats := range all {
+		tubes = append(tubes, statsJSON(stats))
+	}
+	return tubes, nil
+}
+
+// tube routes /tubes/{name} and the operations on the tube
+func (s *apiServer) tube(r *http.Request) (interface{}, error) {
+	// the path is split before unescaping, tube names may contain slashes sent as %2F
+	parts := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/tubes/"), "/")
+	for i, part := range parts {
+		v, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, badRequest("invalid path %s", r.URL.EscapedPath())
+		}
+		parts[i] = v
+	}
+	name := parts[0]
+	if name == "" {
+		return nil, &httpError{http.StatusNotFound, fmt.Errorf("missing tube name")}
+	}
+
+	switch {
+	case len(parts) == 1:
+		if err := allowMethod(r, http.MethodGet); err != nil {
+			return nil, err
+		}
+		stats, err := s.client.TubeStats(name)
+		if err != nil {
+			return nil, err
+		}
+		return statsJSON(stats), nil
+
+	case len(parts) == 3 && parts[1] == "peek":
+		if err := allowMethod(r, http.MethodGet); err != nil {
+			return nil, err
+		}
+		return s.peek(name, parts[2])
+
+	case len(parts) == 2:
+		if err := allowMethod(r, http.MethodPost); err != nil {
+			return nil, err
+		}
+		if err := allowOperation(r); err != nil {
+			return nil, err
+		}
+		res, err := s.operation(name, parts[1], r)
+		if res == nil {
+			return nil, err
+		}
+		// the jobs handled before a failure are reported along with the error
+		if err != nil {
+			return nil, &httpError{errorStatus(err), fmt.Errorf("%s: %v", res.String(), err)}
+		}
+		return &apiResult{res.Tube, res.Operation, res.State, res.Count, res.IDs, res.String()}, nil
+	}
+
+	return nil, &httpError{http.StatusNotFound, fmt.Errorf("unknown path %s", r.URL.Path)}
+}
+
+func (s *apiServer) peek(tubeName, stateName string) (interface{}, error) {
+	state, err := walker.ParseState(stateName)
+	if err != nil {
+		return nil, badRequest("%v", err)
+	}
+	job, err := s.client.Peek(tubeName, state)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.client.JobStats(job.ID)
+	if err != nil {
+		return nil, err
+	}
+	return walker.NewRecord(job, stats), nil
+}
+
+func (s *apiServer) operation(tubeName, op string, r *http.Request) (*walker.Result, error) {
+	q := r.URL.Query()
+
+	switch op {
+	case "kick":
+		n := 0
+		if v := q.Get("n"); v != "" {
+			var err error
+			if n, err = strconv.Atoi(v); err != nil {
+				return nil, badRequest("invalid n %q", v)
+			}
+		}
+		return s.client.Kick(tubeName, n)
+
+	case "bury":
+		return s.client.Bury(tubeName)
+
+	case "delete":
+		state, err := walker.ParseState(q.Get("state"))
+		if err != nil {
+			return nil, badRequest("%v", err)
+		}
+		return s.client.Delete(tubeName, state)
+
+	case "pause":
+		d, err := time.ParseDuration(q.Get("for"))
+		if err != nil {
+			return nil, badRequest("invalid pause duration %q, e.g. 5m", q.Get("for"))
+		}
+		return s.client.Pause(tubeName, d)
+	}
+
+	return nil, &httpError{http.StatusNotFound, fmt.Errorf("unknown operation %s", op)}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/beanstalktest"
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+// newTestAPI returns an API server over a fake server, the requests are served without listening
+func newTestAPI(t *testing.T) (*beanstalktest.Server, *apiServer) {
+	t.Helper()
+
+	s := beanstalktest.NewServer()
+	api, err := listenAPI("127.0.0.1:0", &walker.Dialer{}, s.Addr)
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		api.ln.Close()
+		api.client.Close()
+		s.Close()
+	})
+	return s, api
+}
+
+// serveAPI sends the request and decodes the JSON response
+func serveAPI(api *apiServer, r *http.Request) (int, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	api.srv.Handler.ServeHTTP(w, r)
+	v := map[string]interface{}{}
+	json.NewDecoder(w.Body).Decode(&v)
+	return w.Code, v
+}
+
+func TestAPIOperationGuard(t *testing.T) {
+	s, api := newTestAPI(t)
+	s.Put("emails", []byte("a"), 10, 0, time.Minute)
+
+	tests := []struct {
+		name   string
+		header string
+		origin string
+		want   int
+	}{
+		{"no header", "", "", http.StatusForbidden},
+		{"other origin", "dashboard", "http://evil.example.com", http.StatusForbidden},
+		{"same origin", "dashboard", "http://example.com", http.StatusOK},
+		{"no origin", "curl", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/tubes/emails/pause?for=1s", nil)
+		if tt.header != "" {
+			r.Header.Set(apiHeader, tt.header)
+		}
+		if tt.origin != "" {
+			r.Header.Set("Origin", tt.origin)
+		}
+		if code, v := serveAPI(api, r); code != tt.want {
+			t.Errorf("%s: status %d (%v), want %d", tt.name, code, v, tt.want)
+		}
+	}
+
+	// a refused operation leaves the tube alone
+	r := httptest.NewRequest(http.MethodPost, "/tubes/emails/bury", nil)
+	if code, _ := serveAPI(api, r); code != http.StatusForbidden {
+		t.Fatalf("bury without the header: status %d, want 403", code)
+	}
+	stats, err := api.client.TubeStats("emails")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.CurrentJobsBuried != 0 {
+		t.Errorf("buried = %d after a refused bury, want 0", stats.CurrentJobsBuried)
+	}
+}
+
+func TestAPITubeNameEscaped(t *testing.T) {
+	s, api := newTestAPI(t)
+	s.Put("billing/emails", []byte("a"), 10, 0, time.Minute)
+
+	code, v := serveAPI(api, httptest.NewRequest(http.MethodGet, "/tubes/billing%2Femails", nil))
+	if code != http.StatusOK || v["name"] != "billing/emails" {
+		t.Fatalf("stats: status %d, %v, want billing/emails", code, v)
+	}
+
+	code, v = serveAPI(api, httptest.NewRequest(http.MethodGet, "/tubes/billing%2Femails/peek/ready", nil))
+	if code != http.StatusOK {
+		t.Errorf("peek: status %d, %v, want 200", code, v)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/tubes/billing%2Femails/bury", nil)
+	r.Header.Set(apiHeader, "test")
+	if code, v = serveAPI(api, r); code != http.StatusOK || v["count"] != 1.0 {
+		t.Errorf("bury: status %d, %v, want 1 job buried", code, v)
+	}
+
+	if code, _ = serveAPI(api, httptest.NewRequest(http.MethodGet, "/tubes/billing/emails/peek/ready", nil)); code != http.StatusNotFound {
+		t.Errorf("unescaped slash: status %d, want 404", code)
+	}
+}
+
+func TestAPIColumns(t *testing.T) {
+	_, api := newTestAPI(t)
+
+	w := httptest.NewRecorder()
+	api.srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/columns", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	columns := map[string][]string{}
+	if err := json.NewDecoder(w.Body).Decode(&columns); err != nil {
+		t.Fatal(err)
+	}
+
+	// the grid columns come first, then the stats of the server missing from them
+	system := columns["system"]
+	for i, col := range sysStatsColumns {
+		if i >= len(system) || system[i] != col.Name {
+			t.Fatalf("system columns = %v, want %s at %d", system, col.Name, i)
+		}
+	}
+	extra := strings.Join(system[len(sysStatsColumns):], " ")
+	for _, name := range []string{"cmd-reserve-job", "cmd-touch", "draining", "platform"} {
+		if !strings.Contains(" "+extra+" ", " "+name+" ") {
+			t.Errorf("extra system columns %q miss %s", extra, name)
+		}
+	}
+	if len(columns["tubes"]) != len(tubeStatsColumns) {
+		t.Errorf("tube columns = %v, want the %d grid columns", columns["tubes"], len(tubeStatsColumns))
+	}
+}
+
+func TestAPIKickDelayed(t *testing.T) {
+	s, api := newTestAPI(t)
+	s.Put("emails", []byte("a"), 10, time.Hour, time.Minute)
+	s.Put("emails", []byte("b"), 10, time.Hour, time.Minute)
+
+	// without n and without buried jobs every delayed job is kicked
+	r := httptest.NewRequest(http.MethodPost, "/tubes/emails/kick", nil)
+	r.Header.Set(apiHeader, "test")
+	code, v := serveAPI(api, r)
+	if code != http.StatusOK || v["count"] != 2.0 {
+		t.Fatalf("kick: status %d (%v), want 2 jobs kicked", code, v)
+	}
+	stats, err := api.client.TubeStats("emails")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.CurrentJobsReady != 2 || stats.CurrentJobsDelayed != 0 {
+		t.Errorf("ready/delayed = %d/%d after the kick, want 2/0", stats.CurrentJobsReady, stats.CurrentJobsDelayed)
+	}
+}
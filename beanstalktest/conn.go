@@ -0,0 +1,433 @@
+package beanstalktest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// commandNames lists the commands counted by the server stats
+var commandNames = []string{
+	"put", "peek", "peek-ready", "peek-delayed", "peek-buried", "reserve", "reserve-with-timeout",
+	"touch", "use", "watch", "ignore", "delete", "release", "bury", "kick", "stats", "stats-job",
+	"stats-tube", "list-tubes", "list-tube-used", "list-tubes-watched", "pause-tube", "kick-job", "reserve-job",
+}
+
+type stat struct {
+	key   string
+	value interface{}
+}
+
+type conn struct {
+	s        *Server
+	nc       net.Conn
+	r        *bufio.Reader
+	w        *bufio.Writer
+	use      string
+	watching map[string]bool
+	producer bool
+	worker   bool
+	waiting  bool
+}
+
+func (c *conn) serve() {
+	defer c.nc.Close()
+
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "quit" {
+			return
+		}
+		if err := c.dispatch(strings.Fields(line)); err != nil {
+			return
+		}
+		if err := c.w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (c *conn) reply(format string, args ...interface{}) {
+	fmt.Fprintf(c.w, format+"\r\n", args...)
+}
+
+func (c *conn) replyBody(head string, body []byte) {
+	fmt.Fprintf(c.w, "%s %d\r\n", head, len(body))
+	c.w.Write(body)
+	c.w.WriteString("\r\n")
+}
+
+func (c *conn) replyYAML(stats []stat) {
+	b := &bytes.Buffer{}
+	b.WriteString("---\n")
+	for _, st := range stats {
+		fmt.Fprintf(b, "%s: %v\n", st.key, st.value)
+	}
+	c.replyBody("OK", b.Bytes())
+}
+
+func (c *conn) replyList(names []string) {
+	b := &bytes.Buffer{}
+	b.WriteString("---\n")
+	for _, name := range names {
+		fmt.Fprintf(b, "- %s\n", name)
+	}
+	c.replyBody("OK", b.Bytes())
+}
+
+func (c *conn) dispatch(args []string) error {
+	if len(args) == 0 {
+		c.reply("UNKNOWN_COMMAND")
+		return nil
+	}
+	cmd, args := args[0], args[1:]
+
+	// put reads its body before anything else
+	if cmd == "put" {
+		return c.put(args)
+	}
+
+	// reserve waits without holding the server lock
+	switch cmd {
+	case "reserve":
+		c.reserve(-1)
+		return nil
+	case "reserve-with-timeout":
+		seconds, err := uintArg(args, 0)
+		if err != nil {
+			c.reply("BAD_FORMAT")
+			return nil
+		}
+		c.reserve(time.Duration(seconds) * time.Second)
+		return nil
+	}
+
+	s := c.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tick()
+	s.cmds[cmd]++
+
+	switch cmd {
+	case "use":
+		if len(args) != 1 {
+			c.reply("BAD_FORMAT")
+			break
+		}
+		c.use = args[0]
+		c.reply("USING %s", c.use)
+
+	case "watch":
+		if len(args) != 1 {
+			c.reply("BAD_FORMAT")
+			break
+		}
+		c.watching[args[0]] = true
+		c.reply("WATCHING %d", len(c.watching))
+
+	case "ignore":
+		if len(args) != 1 {
+			c.reply("BAD_FORMAT")
+			break
+		}
+		if c.watching[args[0]] && len(c.watching) == 1 {
+			c.reply("NOT_IGNORED")
+			break
+		}
+		delete(c.watching, args[0])
+		c.reply("WATCHING %d", len(c.watching))
+
+	case "reserve-job":
+		j := c.job(args)
+		if j == nil || j.state == stateReserved {
+			c.reply("NOT_FOUND")
+			break
+		}
+		c.worker = true
+		c.reserveJob(j)
+
+	case "delete":
+		j := c.job(args)
+		if j == nil || (j.state == stateReserved && j.owner != c) {
+			c.reply("NOT_FOUND")
+			break
+		}
+		delete(s.jobs, j.id)
+		s.tube(j.tube).cmdDelete++
+		c.reply("DELETED")
+
+	case "release":
+		j := c.ownJob(args)
+		pri, perr := uintArg(args, 1)
+		delay, derr := uintArg(args, 2)
+		if perr != nil || derr != nil {
+			c.reply("BAD_FORMAT")
+			break
+		}
+		if j == nil {
+			c.reply("NOT_FOUND")
+			break
+		}
+		j.owner = nil
+		j.pri = uint32(pri)
+		j.delay = time.Duration(delay) * time.Second
+		j.releases++
+		j.state = stateReady
+		if delay > 0 {
+			j.state = stateDelayed
+			j.deadline = s.clock().Add(j.delay)
+		}
+		c.reply("RELEASED")
+
+	case "bury":
+		j := c.ownJob(args)
+		pri, err := uintArg(args, 1)
+		if err != nil {
+			c.reply("BAD_FORMAT")
+			break
+		}
+		if j == nil {
+			c.reply("NOT_FOUND")
+			break
+		}
+		j.owner = nil
+		j.pri = uint32(pri)
+		j.buries++
+		j.state = stateBuried
+		c.reply("BURIED")
+
+	case "touch":
+		j := c.ownJob(args)
+		if j == nil {
+			c.reply("NOT_FOUND")
+			break
+		}
+		j.deadline = s.clock().Add(j.ttr)
+		c.reply("TOUCHED")
+
+	case "peek":
+		j := c.job(args)
+		if j == nil {
+			c.reply("NOT_FOUND")
+			break
+		}
+		c.replyBody(fmt.Sprintf("FOUND %d", j.id), j.body)
+
+	case "peek-ready", "peek-delayed", "peek-buried":
+		state := jobState(strings.TrimPrefix(cmd, "peek-"))
+		less := byPriority
+		switch state {
+		case stateDelayed:
+			less = byDeadline
+		case stateBuried:
+			less = byID
+		}
+		j := s.first(func(j *job) bool { return j.tube == c.use && j.state == state }, less)
+		if j == nil {
+			c.reply("NOT_FOUND")
+			break
+		}
+		c.replyBody(fmt.Sprintf("FOUND %d", j.id), j.body)
+
+	case "kick":
+		bound, err := uintArg(args, 0)
+		if err != nil {
+			c.reply("BAD_FORMAT")
+			break
+		}
+		c.reply("KICKED %d", s.kick(c.use, int(bound)))
+
+	case "kick-job":
+		j := c.job(args)
+		if j == nil || (j.state != stateBuried && j.state != stateDelayed) {
+			c.reply("NOT_FOUND")
+			break
+		}
+		j.state = stateReady
+		j.kicks++
+		c.reply("KICKED")
+
+	case "stats":
+		c.replyYAML(s.stats())
+
+	case "stats-job":
+		j := c.job(args)
+		if j == nil {
+			c.reply("NOT_FOUND")
+			break
+		}
+		c.replyYAML(s.jobStats(j))
+
+	case "stats-tube":
+		if len(args) != 1 || !s.tubeExists(args[0]) {
+			c.reply("NOT_FOUND")
+			break
+		}
+		c.replyYAML(s.tubeStats(args[0]))
+
+	case "list-tubes":
+		c.replyList(s.tubeNames())
+
+	case "list-tube-used":
+		c.reply("USING %s", c.use)
+
+	case "list-tubes-watched":
+		names := []string{}
+		for name := range c.watching {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		c.replyList(names)
+
+	case "pause-tube":
+		delay, err := uintArg(args, 1)
+		if err != nil {
+			c.reply("BAD_FORMAT")
+			break
+		}
+		if !s.tubeExists(args[0]) {
+			c.reply("NOT_FOUND")
+			break
+		}
+		t := s.tube(args[0])
+		t.cmdPause++
+		t.pauseDelay = time.Duration(delay) * time.Second
+		t.pauseUntil = s.clock().Add(t.pauseDelay)
+		c.reply("PAUSED")
+
+	default:
+		s.cmds[cmd]--
+		c.reply("UNKNOWN_COMMAND")
+	}
+
+	return nil
+}
+
+func (c *conn) put(args []string) error {
+	pri, perr := uintArg(args, 0)
+	delay, derr := uintArg(args, 1)
+	ttr, terr := uintArg(args, 2)
+	size, serr := uintArg(args, 3)
+	if perr != nil || derr != nil || terr != nil || serr != nil {
+		c.reply("BAD_FORMAT")
+		return nil
+	}
+
+	if size > MaxJobSize {
+		if _, err := io.CopyN(ioutil.Discard, c.r, int64(size)+2); err != nil {
+			return err
+		}
+		c.reply("JOB_TOO_BIG")
+		return nil
+	}
+
+	body := make([]byte, size+2)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return err
+	}
+	if !bytes.HasSuffix(body, []byte("\r\n")) {
+		c.reply("EXPECTED_CRLF")
+		return nil
+	}
+	body = body[:size]
+
+	s := c.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cmds["put"]++
+	c.producer = true
+	if s.draining {
+		c.reply("DRAINING")
+		return nil
+	}
+	j := s.put(c.use, body, uint32(pri), time.Duration(delay)*time.Second, time.Duration(ttr)*time.Second)
+	c.reply("INSERTED %d", j.id)
+
+	return nil
+}
+
+// reserve waits for a ready job on the watched tubes, negative timeout waits forever
+func (c *conn) reserve(timeout time.Duration) {
+	s := c.s
+	s.mu.Lock()
+	if timeout < 0 {
+		s.cmds["reserve"]++
+	} else {
+		s.cmds["reserve-with-timeout"]++
+	}
+	c.worker = true
+	c.waiting = true
+	s.mu.Unlock()
+
+	// the wait is measured by wall time, so a frozen clock does not block forever
+	deadline := time.Now().Add(timeout)
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		s.tick()
+		if j := s.nextReady(c.watching); j != nil {
+			c.waiting = false
+			c.reserveJob(j)
+			s.mu.Unlock()
+			return
+		}
+		if timeout >= 0 && !time.Now().Before(deadline) {
+			c.waiting = false
+			c.reply("TIMED_OUT")
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+
+		time.Sleep(pollDelay)
+	}
+}
+
+func (c *conn) reserveJob(j *job) {
+	j.state = stateReserved
+	j.owner = c
+	j.reserves++
+	j.deadline = c.s.clock().Add(j.ttr)
+	c.replyBody(fmt.Sprintf("RESERVED %d", j.id), j.body)
+}
+
+// job returns the job of the id argument
+func (c *conn) job(args []string) *job {
+	id, err := uintArg(args, 0)
+	if err != nil {
+		return nil
+	}
+	return c.s.jobs[id]
+}
+
+// ownJob returns the job of the id argument reserved by the connection
+func (c *conn) ownJob(args []string) *job {
+	j := c.job(args)
+	if j == nil || j.state != stateReserved || j.owner != c {
+		return nil
+	}
+	return j
+}
+
+func uintArg(args []string, i int) (uint64, error) {
+	if i >= len(args) {
+		return 0, fmt.Errorf("missing argument %d", i)
+	}
+	return strconv.ParseUint(args[i], 10, 64)
+}
@@ -0,0 +1,490 @@
+// Package beanstalktest provides an in-memory beanstalkd server listening on a loopback address,
+// so code talking to beanstalkd can be exercised without a live server.
+package beanstalktest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Version is reported by the stats command
+	Version = "1.12"
+	// Hostname is reported by the stats command, fixed so the output doesn't depend on the machine
+	Hostname = "beanstalktest"
+	// MaxJobSize is the largest accepted job body
+	MaxJobSize = 65535
+
+	urgentPriority = 1024
+	pollDelay      = 10 * time.Millisecond
+)
+
+type jobState string
+
+const (
+	stateReady    jobState = "ready"
+	stateDelayed  jobState = "delayed"
+	stateReserved jobState = "reserved"
+	stateBuried   jobState = "buried"
+)
+
+type job struct {
+	id       uint64
+	tube     string
+	body     []byte
+	pri      uint32
+	delay    time.Duration
+	ttr      time.Duration
+	state    jobState
+	created  time.Time
+	deadline time.Time
+	owner    *conn
+	reserves int
+	timeouts int
+	releases int
+	buries   int
+	kicks    int
+}
+
+type tube struct {
+	name       string
+	totalJobs  int
+	cmdDelete  int
+	cmdPause   int
+	pauseDelay time.Duration
+	pauseUntil time.Time
+}
+
+// Server is an in-memory beanstalkd speaking the text protocol
+// Time dependent behaviours (delays, TTR and tube pauses) are evaluated lazily on every command
+type Server struct {
+	// Addr is the loopback address the server listens on, in host:port form
+	Addr string
+
+	clock      func() time.Time
+	ln         net.Listener
+	mu         sync.Mutex
+	started    time.Time
+	nextID     uint64
+	totalJobs  uint64
+	jobs       map[uint64]*job
+	tubes      map[string]*tube
+	conns      map[*conn]bool
+	totalConns int
+	timeouts   int
+	draining   bool
+	version    string
+	closed     bool
+	cmds       map[string]int
+	wg         sync.WaitGroup
+}
+
+// NewServer starts a server on a random loopback port, it panics when the listener cannot be created
+func NewServer() *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("beanstalktest: failed to listen: %v", err))
+	}
+
+	s := &Server{
+		Addr:    ln.Addr().String(),
+		clock:   time.Now,
+		ln:      ln,
+		jobs:    map[uint64]*job{},
+		tubes:   map[string]*tube{},
+		conns:   map[*conn]bool{},
+		cmds:    map[string]int{},
+		version: Version,
+	}
+	s.started = s.clock()
+	s.tube("default")
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s
+}
+
+// Close stops listening and closes every client connection
+func (s *Server) Close() {
+	s.ln.Close()
+
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.nc.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// SetClock replaces the function returning the current time, to control delays, TTR and tube pauses
+func (s *Server) SetClock(clock func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clock = clock
+}
+
+// SetDraining switches drain mode, a draining server refuses new jobs
+func (s *Server) SetDraining(v bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.draining = v
+}
+
+// Put inserts a job directly, useful to prepare the server state
+func (s *Server) Put(tubeName string, body []byte, pri uint32, delay, ttr time.Duration) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.put(tubeName, body, pri, delay, ttr).id
+}
+
+// SetVersion replaces the version reported by the stats command, Version by default
+func (s *Server) SetVersion(v string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.version = v
+}
+
+// Restart resets the server as a restart replaying the binlog does, the jobs keep their ids but total-jobs
+// counts from zero again
+func (s *Server) Restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.totalJobs = 0
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &conn{
+			s:        s,
+			nc:       nc,
+			r:        bufio.NewReader(nc),
+			w:        bufio.NewWriter(nc),
+			use:      "default",
+			watching: map[string]bool{"default": true},
+		}
+
+		s.mu.Lock()
+		s.conns[c] = true
+		s.totalConns++
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			c.serve()
+
+			s.mu.Lock()
+			s.disconnect(c)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// disconnect releases the jobs reserved by the closed connection
+func (s *Server) disconnect(c *conn) {
+	delete(s.conns, c)
+	for _, j := range s.jobs {
+		if j.state == stateReserved && j.owner == c {
+			j.state = stateReady
+			j.owner = nil
+		}
+	}
+}
+
+func (s *Server) tube(name string) *tube {
+	t, ok := s.tubes[name]
+	if !ok {
+		t = &tube{name: name}
+		s.tubes[name] = t
+	}
+	return t
+}
+
+// tubeNames returns the tubes in use, watched or holding jobs
+func (s *Server) tubeNames() []string {
+	alive := map[string]bool{"default": true}
+	for c := range s.conns {
+		alive[c.use] = true
+		for name := range c.watching {
+			alive[name] = true
+		}
+	}
+	for _, j := range s.jobs {
+		alive[j.tube] = true
+	}
+
+	names := []string{}
+	for name := range alive {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (s *Server) tubeExists(name string) bool {
+	for _, n := range s.tubeNames() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) put(tubeName string, body []byte, pri uint32, delay, ttr time.Duration) *job {
+	if ttr < time.Second {
+		ttr = time.Second
+	}
+	s.nextID++
+	j := &job{
+		id:      s.nextID,
+		tube:    tubeName,
+		body:    body,
+		pri:     pri,
+		delay:   delay,
+		ttr:     ttr,
+		state:   stateReady,
+		created: s.clock(),
+	}
+	if delay > 0 {
+		j.state = stateDelayed
+		j.deadline = j.created.Add(delay)
+	}
+	s.jobs[j.id] = j
+	s.totalJobs++
+	s.tube(tubeName).totalJobs++
+
+	return j
+}
+
+// tick promotes due delayed jobs and returns timed out reservations to ready
+func (s *Server) tick() {
+	now := s.clock()
+	for _, j := range s.jobs {
+		switch {
+		case j.state == stateDelayed && !now.Before(j.deadline):
+			j.state = stateReady
+		case j.state == stateReserved && !now.Before(j.deadline):
+			j.state = stateReady
+			j.owner = nil
+			j.timeouts++
+			s.timeouts++
+		}
+	}
+}
+
+func (s *Server) paused(tubeName string) bool {
+	t, ok := s.tubes[tubeName]
+	return ok && s.clock().Before(t.pauseUntil)
+}
+
+// first returns the job matching the filter which comes first by the comparison
+func (s *Server) first(match func(*job) bool, less func(a, b *job) bool) *job {
+	var found *job
+	for _, j := range s.jobs {
+		if match(j) && (found == nil || less(j, found)) {
+			found = j
+		}
+	}
+	return found
+}
+
+func byPriority(a, b *job) bool {
+	if a.pri != b.pri {
+		return a.pri < b.pri
+	}
+	return a.id < b.id
+}
+
+func byDeadline(a, b *job) bool {
+	if !a.deadline.Equal(b.deadline) {
+		return a.deadline.Before(b.deadline)
+	}
+	return a.id < b.id
+}
+
+func byID(a, b *job) bool {
+	return a.id < b.id
+}
+
+func (s *Server) nextReady(watching map[string]bool) *job {
+	return s.first(func(j *job) bool {
+		return j.state == stateReady && watching[j.tube] && !s.paused(j.tube)
+	}, byPriority)
+}
+
+func (s *Server) kick(tubeName string, bound int) int {
+	state := stateBuried
+	less := byID
+	if s.first(func(j *job) bool { return j.tube == tubeName && j.state == stateBuried }, byID) == nil {
+		state = stateDelayed
+		less = byDeadline
+	}
+
+	n := 0
+	for ; n < bound; n++ {
+		j := s.first(func(j *job) bool { return j.tube == tubeName && j.state == state }, less)
+		if j == nil {
+			break
+		}
+		j.state = stateReady
+		j.kicks++
+	}
+	return n
+}
+
+func (s *Server) countJobs(tubeName string, state jobState) int {
+	n := 0
+	for _, j := range s.jobs {
+		if (tubeName == "" || j.tube == tubeName) && j.state == state {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) countUrgent(tubeName string) int {
+	n := 0
+	for _, j := range s.jobs {
+		if (tubeName == "" || j.tube == tubeName) && j.state == stateReady && j.pri < urgentPriority {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *Server) stats() []stat {
+	producers, workers, waiting := 0, 0, 0
+	for c := range s.conns {
+		if c.producer {
+			producers++
+		}
+		if c.worker {
+			workers++
+		}
+		if c.waiting {
+			waiting++
+		}
+	}
+
+	stats := []stat{
+		{"current-jobs-urgent", s.countUrgent("")},
+		{"current-jobs-ready", s.countJobs("", stateReady)},
+		{"current-jobs-reserved", s.countJobs("", stateReserved)},
+		{"current-jobs-delayed", s.countJobs("", stateDelayed)},
+		{"current-jobs-buried", s.countJobs("", stateBuried)},
+	}
+	for _, name := range commandNames {
+		stats = append(stats, stat{"cmd-" + name, s.cmds[name]})
+	}
+	stats = append(stats, []stat{
+		{"job-timeouts", s.timeouts},
+		{"total-jobs", s.totalJobs},
+		{"max-job-size", MaxJobSize},
+		{"current-tubes", len(s.tubeNames())},
+		{"current-connections", len(s.conns)},
+		{"current-producers", producers},
+		{"current-workers", workers},
+		{"current-waiting", waiting},
+		{"total-connections", s.totalConns},
+		{"pid", os.Getpid()},
+		{"version", s.version},
+		{"rusage-utime", "0.000000"},
+		{"rusage-stime", "0.000000"},
+		{"uptime", int(s.clock().Sub(s.started).Seconds())},
+		{"binlog-oldest-index", 0},
+		{"binlog-current-index", 0},
+		{"binlog-records-migrated", 0},
+		{"binlog-records-written", 0},
+		{"binlog-max-size", 10485760},
+		{"draining", s.draining},
+		{"id", "beanstalktest"},
+		{"hostname", Hostname},
+		{"os", "beanstalktest"},
+		{"platform", "loopback"},
+	}...)
+
+	return stats
+}
+
+func (s *Server) tubeStats(name string) []stat {
+	t := s.tube(name)
+	using, watching, waiting := 0, 0, 0
+	for c := range s.conns {
+		if c.use == name {
+			using++
+		}
+		if c.watching[name] {
+			watching++
+			if c.waiting {
+				waiting++
+			}
+		}
+	}
+
+	pauseLeft := 0
+	if s.paused(name) {
+		pauseLeft = int(t.pauseUntil.Sub(s.clock()).Seconds())
+	}
+
+	return []stat{
+		{"name", name},
+		{"current-jobs-urgent", s.countUrgent(name)},
+		{"current-jobs-ready", s.countJobs(name, stateReady)},
+		{"current-jobs-reserved", s.countJobs(name, stateReserved)},
+		{"current-jobs-delayed", s.countJobs(name, stateDelayed)},
+		{"current-jobs-buried", s.countJobs(name, stateBuried)},
+		{"total-jobs", t.totalJobs},
+		{"current-using", using},
+		{"current-watching", watching},
+		{"current-waiting", waiting},
+		{"cmd-delete", t.cmdDelete},
+		{"cmd-pause-tube", t.cmdPause},
+		{"pause", int(t.pauseDelay.Seconds())},
+		{"pause-time-left", pauseLeft},
+	}
+}
+
+func (s *Server) jobStats(j *job) []stat {
+	now := s.clock()
+	left := 0
+	if j.state == stateDelayed || j.state == stateReserved {
+		left = int(j.deadline.Sub(now).Seconds())
+	}
+
+	return []stat{
+		{"id", j.id},
+		{"tube", j.tube},
+		{"state", string(j.state)},
+		{"pri", j.pri},
+		{"age", int(now.Sub(j.created).Seconds())},
+		{"delay", int(j.delay.Seconds())},
+		{"ttr", int(j.ttr.Seconds())},
+		{"time-left", left},
+		{"file", 0},
+		{"reserves", j.reserves},
+		{"timeouts", j.timeouts},
+		{"releases", j.releases},
+		{"buries", j.buries},
+		{"kicks", j.kicks},
+	}
+}
@@ -0,0 +1,51 @@
+package beanstalktest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kr/beanstalk"
+)
+
+func TestSetClock(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c, err := beanstalk.Dial("tcp", s.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	start := time.Unix(1500000000, 0)
+	s.SetClock(func() time.Time { return start })
+	id := s.Put("default", []byte("a"), 10, time.Minute, time.Minute)
+
+	if _, _, err := c.PeekReady(); err == nil {
+		t.Fatal("the delayed job is ready before its delay")
+	}
+
+	s.SetClock(func() time.Time { return start.Add(time.Minute) })
+	got, _, err := c.PeekReady()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != id {
+		t.Errorf("ready job %d, want %d", got, id)
+	}
+}
+
+func TestDrainingRefusesPut(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c, err := beanstalk.Dial("tcp", s.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	s.SetDraining(true)
+	_, err = c.Put([]byte("a"), 10, 0, time.Minute)
+	if cerr, ok := err.(beanstalk.ConnError); !ok || cerr.Err != beanstalk.ErrDraining {
+		t.Errorf("put into draining server: err = %v, want DRAINING", err)
+	}
+}
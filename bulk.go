@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kadekcipta/beanwalker/query"
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+const (
+	bulkFilterPrompt = "Jobs where (e.g. age > 1d and releases >= 5, 'legacy' or .version < 3): "
+	bulkLabel        = "Bulk %s in %s"
+	bulkActionPrompt = "%d jobs match (%d ready, %d delayed, %d buried), delete, kick or move <tube>: "
+)
+
+// jobFilter returns the walker filter of the predicate, the body is matched decoded by the decoder of the tube
+func jobFilter(config *Config, tubeName string, f query.Filter) walker.JobFilter {
+	return func(job *walker.Job, stats *walker.JobStats) bool {
+		return f.Match(walker.FieldMap(stats), config.decodedBody(tubeName, job.Body))
+	}
+}
+
+// promptBulk asks for the predicate, previews the matching jobs and then asks for the operation
+func (m *mainFrame) promptBulk() error {
+	tubeName := m.currentTubeName()
+	if tubeName == "" {
+		return nil
+	}
+	m.ask(bulkFilterPrompt, func(expr string) error {
+		return m.previewBulk(tubeName, expr)
+	})
+	return nil
+}
+
+// previewBulk counts the jobs matching the predicate with a dry run and asks for the operation to apply
+// The jobs are scanned in the background, as the operation is
+func (m *mainFrame) previewBulk(tubeName, expr string) error {
+	f, err := query.ParseFilter(expr)
+	if err != nil {
+		return err
+	}
+	ids, err := m.client.LastIDs(scanIDs)
+	if err != nil {
+		return err
+	}
+
+	filter := jobFilter(m.config, tubeName, f)
+	counts := map[walker.State]int{}
+	sel := walker.Selection{
+		Tube:   tubeName,
+		States: []walker.State{walker.StateReady, walker.StateDelayed, walker.StateBuried},
+		Range:  ids,
+		Filter: func(job *walker.Job, stats *walker.JobStats) bool {
+			if filter(job, stats) {
+				counts[stats.State]++
+				return true
+			}
+			return false
+		},
+		DryRun: true,
+	}
+
+	var r *walker.Result
+	preview := func(c *walker.Client) (err error) {
+		r, err = c.DeleteWhere(sel)
+		return err
+	}
+	return m.startScan(fmt.Sprintf(bulkLabel, expr, tubeName), ids, preview, func() error {
+		if r.Count == 0 {
+			m.showStatus(fmt.Sprintf("%s: no jobs match %s", tubeName, expr))
+			return nil
+		}
+
+		label := fmt.Sprintf(bulkActionPrompt, r.Count, counts[walker.StateReady], counts[walker.StateDelayed], counts[walker.StateBuried])
+		m.ask(label, func(action string) error {
+			sel.Filter = filter
+			sel.DryRun = false
+			return m.runBulk(sel, action)
+		})
+		return nil
+	})
+}
+
+// runBulk applies the operation to the selected jobs
+func (m *mainFrame) runBulk(sel walker.Selection, action string) error {
+	var op func(c *walker.Client) (*walker.Result, error)
+
+	fields := strings.Fields(action)
+	switch {
+	case len(fields) == 1 && fields[0] == "delete":
+		op = func(c *walker.Client) (*walker.Result, error) {
+			return c.DeleteWhere(sel)
+		}
+	case len(fields) == 1 && fields[0] == "kick":
+		sel.States = []walker.State{walker.StateBuried, walker.StateDelayed}
+		op = func(c *walker.Client) (*walker.Result, error) {
+			return c.KickWhere(sel)
+		}
+	case len(fields) == 2 && fields[0] == "move":
+		op = func(c *walker.Client) (*walker.Result, error) {
+			return c.MoveWhere(sel, fields[1])
+		}
+	case len(fields) == 0:
+		return nil
+	default:
+		return fmt.Errorf("unknown operation %q, expected delete, kick or move <tube>", action)
+	}
+
+	// the jobs done before a failure are reported along with the error
+	var r *walker.Result
+	run := func(c *walker.Client) (err error) {
+		if r, err = op(c); err != nil && r != nil {
+			err = fmt.Errorf("%s, %v", r, err)
+		}
+		return err
+	}
+	return m.startScan(fmt.Sprintf(bulkLabel, action, sel.Tube), sel.Range, run, func() error {
+		m.showStatus(r.String())
+		return nil
+	})
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// answer types the text into the prompt and submits it
+func answer(t *testing.T, m *mainFrame, text string) {
+	t.Helper()
+
+	if !m.prompt.Visible() {
+		t.Fatal("no prompt to answer")
+	}
+	for _, r := range text {
+		m.dispatchEvent(termbox.Event{Type: termbox.EventKey, Ch: r})
+	}
+	m.dispatchEvent(keyEvent(termbox.KeyEnter))
+}
+
+func TestBulk(t *testing.T) {
+	s, m := newTestFrame(t, 100, 24)
+	s.Put("emails", []byte(`{"version":2}`), 10, time.Hour, time.Minute)
+	s.Put("emails", []byte(`{"version":3}`), 10, 0, time.Minute)
+	press(m, termbox.KeyTab)
+	press(m, termbox.KeyArrowDown)
+
+	press(m, termbox.KeyCtrlB)
+	answer(t, m, ".version < 3 or .user_id")
+	finishScan(t, m)
+	if label := m.prompt.Label; label != "2 jobs match (1 ready, 1 delayed, 0 buried), delete, kick or move <tube>: " {
+		t.Fatalf("action prompt = %q", label)
+	}
+
+	answer(t, m, "move archive")
+	finishScan(t, m)
+	if line := frameBuffer(m).Line(23); !strings.HasPrefix(line, " emails: 2 jobs moved to archive") {
+		t.Errorf("status line = %q", line)
+	}
+	if stats := tubeStats(t, m, "archive"); stats.CurrentJobsReady != 1 || stats.CurrentJobsDelayed != 1 {
+		t.Errorf("archive ready/delayed = %d/%d, want 1/1", stats.CurrentJobsReady, stats.CurrentJobsDelayed)
+	}
+	if stats := tubeStats(t, m, "emails"); stats.CurrentJobsReady != 1 {
+		t.Errorf("emails ready = %d, want 1", stats.CurrentJobsReady)
+	}
+}
+
+func TestBulkNoMatch(t *testing.T) {
+	_, m := newTestFrame(t, 100, 24)
+	press(m, termbox.KeyTab)
+	press(m, termbox.KeyArrowDown)
+
+	press(m, termbox.KeyCtrlB)
+	answer(t, m, "age > 1d")
+	finishScan(t, m)
+	if m.prompt.Visible() {
+		t.Error("the action is asked with no matching job")
+	}
+	if line := frameBuffer(m).Line(23); !strings.HasPrefix(line, " emails: no jobs match age > 1d") {
+		t.Errorf("status line = %q", line)
+	}
+}
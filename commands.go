@@ -0,0 +1,712 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/query"
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+// subcommands run without the user interface, as beanwalker <name> [flags]
+var subcommands = map[string]func(args []string) error{
+	"dump":    runDump,
+	"restore": runRestore,
+	"migrate": runMigrate,
+	"kick":    runKick,
+	"bury":    runBury,
+	"delete":  runDelete,
+	"pause":   runPause,
+	"put":     runPut,
+	"top":     runTop,
+	"serve":   runServe,
+}
+
+// usageError reports missing or invalid flags, the process exits with 2 as for flag parse errors
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// dialFlags are the flags of how beanstalkd is connected to, shared by the user interface and the subcommands
+type dialFlags struct {
+	timeout     time.Duration
+	ssh         string
+	sshKey      string
+	knownHosts  string
+	tls         bool
+	tlsCA       string
+	tlsCert     string
+	tlsKey      string
+	tlsServer   string
+	tlsInsecure bool
+}
+
+func (d *dialFlags) register(f *flag.FlagSet) {
+	d.registerPrefixed(f, "")
+}
+
+// registerPrefixed registers the flags with the prefix before their name, e.g. -from-ssh
+func (d *dialFlags) registerPrefixed(f *flag.FlagSet, prefix string) {
+	f.DurationVar(&d.timeout, prefix+"timeout", defaultTimeout, "connect timeout")
+	f.StringVar(&d.ssh, prefix+"ssh", "", "connect through the SSH jump host, e.g. user@bastion or user@bastion:2222")
+	f.StringVar(&d.sshKey, prefix+"ssh-key", "", "private key of the SSH jump host, after the agent keys (default ~/.ssh/id_ed25519, id_ecdsa and id_rsa)")
+	f.StringVar(&d.knownHosts, prefix+"ssh-known-hosts", "", "known_hosts file checking the SSH jump host key (default ~/.ssh/known_hosts)")
+	f.BoolVar(&d.tls, prefix+"tls", false, "connect over TLS, implied by the other -"+prefix+"tls flags")
+	f.StringVar(&d.tlsCA, prefix+"tls-ca", "", "PEM bundle of the certificate authorities trusted (default the system ones)")
+	f.StringVar(&d.tlsCert, prefix+"tls-cert", "", "client certificate file, along with -"+prefix+"tls-key")
+	f.StringVar(&d.tlsKey, prefix+"tls-key", "", "client certificate key file")
+	f.StringVar(&d.tlsServer, prefix+"tls-server-name", "", "name checked against the server certificate (default the host)")
+	f.BoolVar(&d.tlsInsecure, prefix+"tls-insecure", false, "accept any server certificate, for labs only")
+}
+
+func (d *dialFlags) dialer() *walker.Dialer {
+	dialer := &walker.Dialer{Timeout: d.timeout}
+	if d.ssh != "" {
+		dialer.SSH = &walker.SSHConfig{Target: d.ssh, KnownHosts: d.knownHosts}
+		if d.sshKey != "" {
+			dialer.SSH.KeyFiles = []string{d.sshKey}
+		}
+	}
+	if d.tls || d.tlsCA != "" || d.tlsCert != "" || d.tlsKey != "" || d.tlsServer != "" || d.tlsInsecure {
+		dialer.TLS = &walker.TLSConfig{
+			CAFile:             d.tlsCA,
+			CertFile:           d.tlsCert,
+			KeyFile:            d.tlsKey,
+			ServerName:         d.tlsServer,
+			InsecureSkipVerify: d.tlsInsecure,
+		}
+	}
+	return dialer
+}
+
+// commandFlags are the flags of a subcommand, along with the server address ones
+type commandFlags struct {
+	*flag.FlagSet
+	dialFlags
+	host       string
+	port       int
+	configPath string
+	profile    string
+	config     *Config
+}
+
+func newCommandFlags(name string) *commandFlags {
+	f := &commandFlags{FlagSet: flag.NewFlagSet(name, flag.ExitOnError)}
+	f.StringVar(&f.host, "h", "127.0.0.1", hostUsage)
+	f.IntVar(&f.port, "p", walker.DefaultPort, "beanstalkd port")
+	f.register(f.FlagSet)
+	f.StringVar(&f.configPath, "config", "", configUsage)
+	f.StringVar(&f.profile, "profile", "", profileUsage)
+	return f
+}
+
+// Parse parses the arguments and loads the configuration, the flags not given are taken from the profile
+func (f *commandFlags) Parse(args []string) {
+	f.FlagSet.Parse(args)
+
+	config, err := loadConfig(f.configPath)
+	if err == nil {
+		err = config.applyProfile(f.FlagSet, f.profile, "")
+	}
+	if err != nil {
+		fmt.Fprintf(f.Output(), "%s: %v\n", f.Name(), err)
+		os.Exit(2)
+	}
+	f.config = config
+}
+
+func (f *commandFlags) addr() string {
+	return walker.HostPortAddr(f.host, f.port)
+}
+
+func (f *commandFlags) dial() (*walker.Client, error) {
+	return f.dialer().Dial(f.addr())
+}
+
+// parseStates parses a comma separated list of job states
+func parseStates(list string) ([]walker.State, error) {
+	states := []walker.State{}
+	for _, name := range strings.Split(list, ",") {
+		s, err := walker.ParseState(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, s)
+	}
+	return states, nil
+}
+
+// scanTruncatedWarning tells the older jobs were left out of the scan
+const scanTruncatedWarning = "warning: job ids below %d were not scanned, -scan 1: scans every id"
+
+// parseScanRange returns the job ids of the -scan value, top is the highest job id of the server
+// The value is from:to, from: or :to, or the number of the last ids, the last scanIDs ids when empty
+func parseScanRange(value string, top uint64) (walker.ScanRange, error) {
+	r := walker.ScanRange{From: 1, To: top}
+	if value == "" {
+		value = strconv.Itoa(scanIDs)
+	}
+
+	i := strings.IndexByte(value, ':')
+	if i < 0 {
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || n == 0 {
+			return r, usageError(fmt.Sprintf("-scan: invalid value %q, expected from:to or a number of ids", value))
+		}
+		if top > n {
+			r.From = top - n + 1
+		}
+		return r, nil
+	}
+
+	var err error
+	if from := value[:i]; from != "" {
+		if r.From, err = strconv.ParseUint(from, 10, 64); err != nil || r.From == 0 {
+			return r, usageError(fmt.Sprintf("-scan: invalid first job id %q", from))
+		}
+	}
+	if to := value[i+1:]; to != "" {
+		if r.To, err = strconv.ParseUint(to, 10, 64); err != nil || r.To < r.From {
+			return r, usageError(fmt.Sprintf("-scan: invalid last job id %q", to))
+		}
+	}
+	return r, nil
+}
+
+// scanRange returns the job ids of the -scan value on the server, the range is printed to the standard error
+// along with a warning when older ids are left out
+func scanRange(c *walker.Client, value string) (walker.ScanRange, error) {
+	top, err := c.TopID()
+	if err != nil {
+		return walker.ScanRange{}, err
+	}
+	r, err := parseScanRange(value, top)
+	if err != nil {
+		return r, err
+	}
+
+	fmt.Fprintf(os.Stderr, "job ids %d-%d scanned\n", r.From, r.To)
+	if r.Truncated() {
+		fmt.Fprintf(os.Stderr, scanTruncatedWarning+"\n", r.From)
+	}
+	return r, nil
+}
+
+// scanFlag registers the -scan range of the probed job ids
+func scanFlag(f *flag.FlagSet) *string {
+	return f.String("scan", "", fmt.Sprintf("job ids probed, from:to with either end optional or the number of the last ids (default the last %d ids)", scanIDs))
+}
+
+// writeDump dumps the jobs of the states in the tube whose id is in the range to the file, "-" is the standard
+// output
+func writeDump(c *walker.Client, path, tubeName string, states []walker.State, ids walker.ScanRange) (*walker.Result, error) {
+	var w io.Writer = os.Stdout
+	if path != "-" {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	return c.Dump(w, tubeName, states, ids)
+}
+
+func runDump(args []string) error {
+	f := newCommandFlags("dump")
+	tubeName := f.String("tube", "", "tube to dump")
+	stateList := f.String("states", "ready,delayed,buried", "comma separated job states to dump")
+	output := f.String("o", "-", "dump file, JSON Lines with base64 bodies, - for the standard output")
+	scan := scanFlag(f.FlagSet)
+	f.Parse(args)
+
+	if *tubeName == "" {
+		return usageError("dump: missing -tube")
+	}
+	states, err := parseStates(*stateList)
+	if err != nil {
+		return err
+	}
+
+	c, err := f.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	ids, err := scanRange(c, *scan)
+	if err != nil {
+		return err
+	}
+	r, err := writeDump(c, *output, *tubeName, states, ids)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, r.String())
+
+	return nil
+}
+
+// restoreProgressEvery is the number of restored jobs between progress lines
+const restoreProgressEvery = 1000
+
+func runRestore(args []string) error {
+	f := newCommandFlags("restore")
+	input := f.String("f", "", "dump file written by dump, - for the standard input")
+	tubeName := f.String("tube", "", "tube to restore into instead of the dumped one")
+	bury := f.Bool("bury", false, "bury again the jobs dumped as buried, they are restored ready otherwise, needs beanstalkd 1.12 or later")
+	rate := f.Float64("rate", 0, "maximum jobs restored per second, no limit when 0")
+	progress := f.String("progress", "", "progress marker file, restored jobs are skipped when restoring again (default <file>.progress)")
+	f.Parse(args)
+
+	if *input == "" {
+		return usageError("restore: missing -f")
+	}
+
+	r := io.Reader(os.Stdin)
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+		if *progress == "" {
+			*progress = *input + ".progress"
+		}
+	}
+
+	// the marker holds the number of records already restored
+	skip := 0
+	if *progress != "" {
+		if b, err := ioutil.ReadFile(*progress); err == nil {
+			if skip, err = strconv.Atoi(strings.TrimSpace(string(b))); err != nil {
+				return fmt.Errorf("restore: invalid progress marker %s: %v", *progress, err)
+			}
+		}
+	}
+
+	c, err := f.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if err := c.CheckDraining(); err != nil {
+		return err
+	}
+	if *bury {
+		if err := c.CheckReserveJob(); err != nil {
+			return fmt.Errorf("restore: -bury: %v", err)
+		}
+	}
+
+	var tick <-chan time.Time
+	if *rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	read, restored := 0, 0
+	err = walker.ReadDump(r, func(rec *walker.Record) error {
+		read++
+		if read <= skip {
+			return nil
+		}
+		if tick != nil {
+			<-tick
+		}
+		if _, err := c.Restore(rec, *tubeName, *bury); err != nil {
+			return fmt.Errorf("restore: job #%d: %v", rec.ID, err)
+		}
+		restored++
+		if *progress != "" {
+			if err := ioutil.WriteFile(*progress, []byte(strconv.Itoa(read)), 0644); err != nil {
+				return err
+			}
+		}
+		if restored%restoreProgressEvery == 0 {
+			fmt.Fprintf(os.Stderr, "%d jobs restored\n", restored)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if skip > 0 {
+		fmt.Fprintf(os.Stderr, "%d jobs restored, %d restored before skipped\n", restored, skip)
+	} else {
+		fmt.Fprintf(os.Stderr, "%d jobs restored\n", restored)
+	}
+	// a complete restore starts over the next time
+	if *progress != "" {
+		os.Remove(*progress)
+	}
+
+	return nil
+}
+
+// endpointFlags are the server flags of one side of migrate, named after the side, e.g. -from-ssh
+type endpointFlags struct {
+	dialFlags
+	side    string
+	addr    string
+	profile string
+}
+
+func newEndpointFlags(f *flag.FlagSet, side, addr, usage string) *endpointFlags {
+	e := &endpointFlags{side: side}
+	f.StringVar(&e.addr, side, addr, usage)
+	f.StringVar(&e.profile, side+"-profile", "", fmt.Sprintf("connection profile of the -config file for the %s server, the -%s flags given take precedence", side, side))
+	e.registerPrefixed(f, side+"-")
+	return e
+}
+
+// applyProfile sets the flags of the side not given on the command line to the settings of its profile
+// The address is made of the host and port of the profile unless given
+func (e *endpointFlags) applyProfile(f *flag.FlagSet, config *Config) error {
+	if err := config.applyProfile(f, e.profile, e.side+"-"); err != nil {
+		return fmt.Errorf("-%s-profile: %v", e.side, err)
+	}
+
+	given := false
+	f.Visit(func(fl *flag.Flag) {
+		given = given || fl.Name == e.side
+	})
+	if p := config.Profiles[e.profile]; e.profile != "" && !given && p.Host != "" {
+		port := p.Port
+		if port == 0 {
+			port = walker.DefaultPort
+		}
+		e.addr = walker.HostPortAddr(p.Host, port)
+	}
+	return nil
+}
+
+func (e *endpointFlags) dial() (*walker.Client, error) {
+	return e.dialer().Dial(e.addr)
+}
+
+// migrateFlags are the flags of migrate, the source and target servers have their own connection flags
+type migrateFlags struct {
+	*flag.FlagSet
+	from, to   *endpointFlags
+	configPath string
+	tube       string
+	destTube   string
+	stateList  string
+	states     []walker.State
+	rate       float64
+	scan       *string
+	bury       bool
+}
+
+func newMigrateFlags() *migrateFlags {
+	f := &migrateFlags{FlagSet: flag.NewFlagSet("migrate", flag.ExitOnError)}
+	f.from = newEndpointFlags(f.FlagSet, "from", "127.0.0.1:11300", "source beanstalkd address, e.g. host:port or unix:///run/beanstalkd.sock")
+	f.to = newEndpointFlags(f.FlagSet, "to", "", "target beanstalkd address")
+	f.StringVar(&f.configPath, "config", "", configUsage)
+	f.StringVar(&f.tube, "tube", "", "tube to migrate")
+	f.StringVar(&f.destTube, "dest-tube", "", "tube on the target server (default the migrated tube)")
+	f.StringVar(&f.stateList, "states", "ready,delayed,buried", "comma separated job states to migrate")
+	f.Float64Var(&f.rate, "rate", 0, "maximum jobs migrated per second, no limit when 0")
+	f.BoolVar(&f.bury, "bury", true, "bury again the buried jobs on the target, which needs beanstalkd 1.12 or later there, they are migrated ready otherwise")
+	f.scan = scanFlag(f.FlagSet)
+	return f
+}
+
+// parse parses the arguments and applies the profiles of both servers
+func (f *migrateFlags) parse(args []string) error {
+	f.Parse(args)
+
+	config, err := loadConfig(f.configPath)
+	if err != nil {
+		return usageError("migrate: " + err.Error())
+	}
+	for _, e := range []*endpointFlags{f.from, f.to} {
+		if err := e.applyProfile(f.FlagSet, config); err != nil {
+			return usageError("migrate: " + err.Error())
+		}
+	}
+
+	switch {
+	case f.to.addr == "":
+		return usageError("migrate: missing -to or -to-profile")
+	case f.tube == "":
+		return usageError("migrate: missing -tube")
+	case f.destTube == "":
+		f.destTube = f.tube
+	}
+	if f.states, err = parseStates(f.stateList); err != nil {
+		return usageError("migrate: " + err.Error())
+	}
+	return nil
+}
+
+func runMigrate(args []string) error {
+	f := newMigrateFlags()
+	if err := f.parse(args); err != nil {
+		return err
+	}
+	from, to := f.from.addr, f.to.addr
+
+	src, err := f.from.dial()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := f.to.dial()
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if err := dst.CheckDraining(); err != nil {
+		return fmt.Errorf("migrate: %s: %v", to, err)
+	}
+	// buried jobs are put held first, they would become ready if burying them fails
+	bury := false
+	for _, s := range f.states {
+		bury = bury || (f.bury && s == walker.StateBuried)
+	}
+	if bury {
+		if err := dst.CheckReserveJob(); err != nil {
+			return fmt.Errorf("migrate: %s: %v, -bury=false migrates the buried jobs ready", to, err)
+		}
+	}
+	ids, err := scanRange(src, *f.scan)
+	if err != nil {
+		return err
+	}
+
+	var tick <-chan time.Time
+	if f.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / f.rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	// at least once, the source job is deleted only once the target one is put
+	start := time.Now()
+	counts := map[walker.State]int{}
+	migrated := 0
+	err = src.Scan(f.tube, f.states, ids, func(job *walker.Job, stats *walker.JobStats) error {
+		if tick != nil {
+			<-tick
+		}
+		rec := walker.NewRecord(job, stats)
+		if _, err := dst.Restore(rec, f.destTube, f.bury); err != nil {
+			return fmt.Errorf("migrate: job #%d: put: %v", job.ID, err)
+		}
+		if err := src.DeleteJob(job.ID); err != nil && !walker.IsNotFound(err) {
+			return fmt.Errorf("migrate: job #%d: put but not deleted from %s: %v", job.ID, from, err)
+		}
+		counts[stats.State]++
+		migrated++
+		return nil
+	})
+
+	elapsed := time.Since(start)
+	fmt.Fprintf(os.Stderr, "%s %s -> %s %s: %d jobs migrated (%d ready, %d delayed, %d buried) in %s, %.1f jobs/s\n",
+		from, f.tube, to, f.destTube, migrated,
+		counts[walker.StateReady], counts[walker.StateDelayed], counts[walker.StateBuried],
+		elapsed.Round(time.Millisecond), float64(migrated)/elapsed.Seconds())
+
+	return err
+}
+
+// tubeFlag registers the -tube flag every tube operation requires
+func (f *commandFlags) tubeFlag() *string {
+	return f.String("tube", "", "tube name")
+}
+
+// whereFlag registers the -where predicate, the body is matched decoded by the decoders of the -config file
+func (f *commandFlags) whereFlag() *string {
+	return f.String("where", "", "only the jobs matching the predicate, e.g. \"age > 1d and releases >= 5\"")
+}
+
+// selection returns the jobs of the states in the tube matching the predicate, among the job ids of -scan
+func selection(c *walker.Client, config *Config, tubeName string, states []walker.State, where, scan string) (walker.Selection, error) {
+	sel := walker.Selection{Tube: tubeName, States: states}
+
+	f, err := query.ParseFilter(where)
+	if err != nil {
+		return sel, usageError(fmt.Sprintf("-where: %v", err))
+	}
+	if sel.Range, err = scanRange(c, scan); err != nil {
+		return sel, err
+	}
+	sel.Filter = jobFilter(config, tubeName, f)
+
+	return sel, nil
+}
+
+// runTubeOperation dials the server and prints the result of the operation
+func runTubeOperation(f *commandFlags, tubeName string, op func(c *walker.Client) (*walker.Result, error)) error {
+	if tubeName == "" {
+		return usageError(f.Name() + ": missing -tube")
+	}
+
+	c, err := f.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	r, err := op(c)
+	if r != nil {
+		fmt.Println(r.String())
+	}
+	return err
+}
+
+func runKick(args []string) error {
+	f := newCommandFlags("kick")
+	tubeName := f.tubeFlag()
+	n := f.Int("n", 0, "maximum number of jobs to kick, the buried ones or the delayed ones when none is buried, all of them when 0")
+	where := f.whereFlag()
+	scan := scanFlag(f.FlagSet)
+	f.Parse(args)
+
+	if *n != 0 && *where != "" {
+		return usageError("kick: -n cannot be used with -where, every matching job is kicked")
+	}
+
+	return runTubeOperation(f, *tubeName, func(c *walker.Client) (*walker.Result, error) {
+		if *where == "" {
+			return c.Kick(*tubeName, *n)
+		}
+		// every buried and delayed job matching is kicked
+		sel, err := selection(c, f.config, *tubeName, []walker.State{walker.StateBuried, walker.StateDelayed}, *where, *scan)
+		if err != nil {
+			return nil, err
+		}
+		return c.KickWhere(sel)
+	})
+}
+
+func runBury(args []string) error {
+	f := newCommandFlags("bury")
+	tubeName := f.tubeFlag()
+	f.Parse(args)
+
+	return runTubeOperation(f, *tubeName, func(c *walker.Client) (*walker.Result, error) {
+		return c.Bury(*tubeName)
+	})
+}
+
+func runDelete(args []string) error {
+	f := newCommandFlags("delete")
+	tubeName := f.tubeFlag()
+	stateName := f.String("state", "", "state of the jobs to delete, ready, delayed or buried")
+	where := f.whereFlag()
+	dryRun := f.Bool("dry-run", false, "only count the jobs matching -where")
+	scan := scanFlag(f.FlagSet)
+	f.Parse(args)
+
+	state, err := walker.ParseState(*stateName)
+	if err != nil {
+		return usageError("delete: " + err.Error())
+	}
+	if *dryRun && *where == "" {
+		return usageError("delete: -dry-run requires -where")
+	}
+
+	return runTubeOperation(f, *tubeName, func(c *walker.Client) (*walker.Result, error) {
+		if *where == "" {
+			return c.Delete(*tubeName, state)
+		}
+		sel, err := selection(c, f.config, *tubeName, []walker.State{state}, *where, *scan)
+		if err != nil {
+			return nil, err
+		}
+		sel.DryRun = *dryRun
+		return c.DeleteWhere(sel)
+	})
+}
+
+func runPause(args []string) error {
+	f := newCommandFlags("pause")
+	tubeName := f.tubeFlag()
+	d := f.Duration("for", 0, "pause duration, e.g. 5m, 0 resumes the tube")
+	f.Parse(args)
+
+	return runTubeOperation(f, *tubeName, func(c *walker.Client) (*walker.Result, error) {
+		return c.Pause(*tubeName, *d)
+	})
+}
+
+// runPut prints the id of the new job
+func runPut(args []string) error {
+	f := newCommandFlags("put")
+	tubeName := f.tubeFlag()
+	input := f.String("f", "-", "job body file, - for the standard input")
+	body := f.String("body", "", "job body, instead of -f")
+	pri := f.Uint("pri", 1024, "job priority, the lower the more urgent")
+	delay := f.Duration("delay", 0, "delay before the job is ready")
+	ttr := f.Duration("ttr", time.Minute, "time to run")
+	f.Parse(args)
+
+	if *tubeName == "" {
+		return usageError("put: missing -tube")
+	}
+	if *pri > math.MaxUint32 {
+		return usageError(fmt.Sprintf("put: -pri %d is out of range, the lowest priority is %d", *pri, uint32(math.MaxUint32)))
+	}
+
+	b := []byte(*body)
+	if *body == "" {
+		var err error
+		if *input == "-" {
+			b, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			b, err = ioutil.ReadFile(*input)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	c, err := f.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	id, err := c.Put(*tubeName, b, uint32(*pri), *delay, *ttr)
+	if err != nil {
+		return err
+	}
+	fmt.Println(id)
+
+	return nil
+}
+
+// runServe serves the HTTP API and the dashboard without the user interface
+func runServe(args []string) error {
+	f := newCommandFlags("serve")
+	addr := f.String("http", "127.0.0.1:8080", "HTTP listen address of the API and the dashboard, e.g. :8080 for every interface")
+	interval := f.Duration("i", 2*time.Second, "refresh interval of the dashboard")
+	f.Parse(args)
+
+	s, err := listenAPI(*addr, f.dialer(), f.addr())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "serving the API and the dashboard of %s on %s\n", f.addr(), s.ln.Addr())
+
+	go s.poll(*interval)
+	return s.Serve()
+}
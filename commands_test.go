@@ -0,0 +1,202 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/beanstalktest"
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+func TestParseScanRange(t *testing.T) {
+	tests := []struct {
+		value string
+		top   uint64
+		from  uint64
+		to    uint64
+	}{
+		{"", 42, 1, 42},
+		{"", 250000, 150001, 250000},
+		{"10", 42, 33, 42},
+		{"100", 42, 1, 42},
+		{"5:20", 42, 5, 20},
+		{"5:", 42, 5, 42},
+		{":20", 42, 1, 20},
+		{"1:", 250000, 1, 250000},
+		{"30:100", 42, 30, 100},
+	}
+	for _, tt := range tests {
+		got, err := parseScanRange(tt.value, tt.top)
+		if err != nil {
+			t.Errorf("parseScanRange(%q, %d): %v", tt.value, tt.top, err)
+			continue
+		}
+		if got != (walker.ScanRange{From: tt.from, To: tt.to}) {
+			t.Errorf("parseScanRange(%q, %d) = %d-%d, want %d-%d", tt.value, tt.top, got.From, got.To, tt.from, tt.to)
+		}
+	}
+
+	for _, value := range []string{"0", "-5", "x", "0:10", "a:", ":b", "20:10"} {
+		if _, err := parseScanRange(value, 42); err == nil {
+			t.Errorf("parseScanRange(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func writeConfig(t *testing.T, config string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "beanwalker.json")
+	if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMigrateFlags(t *testing.T) {
+	path := writeConfig(t, `{"profiles": {
+		"old": {"host": "10.0.0.1", "ssh": "ops@bastion", "timeout": "3s"},
+		"new": {"host": "10.0.0.2", "port": 11301, "tls": {"ca": "ca.pem"}}
+	}}`)
+
+	f := newMigrateFlags()
+	err := f.parse([]string{"-config", path, "-from-profile", "old", "-to-profile", "new", "-to-tls-server-name", "beanstalk", "-tube", "emails"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.from.addr != "10.0.0.1:11300" || f.from.ssh != "ops@bastion" || f.from.timeout != 3*time.Second || f.from.tls {
+		t.Errorf("from = %s over ssh %q in %s, tls %v", f.from.addr, f.from.ssh, f.from.timeout, f.from.tls)
+	}
+	if f.to.addr != "10.0.0.2:11301" || f.to.ssh != "" || f.to.tlsCA != "ca.pem" || f.to.tlsServer != "beanstalk" {
+		t.Errorf("to = %s over ssh %q, tls ca %q and server name %q", f.to.addr, f.to.ssh, f.to.tlsCA, f.to.tlsServer)
+	}
+	if f.destTube != "emails" {
+		t.Errorf("dest tube = %q, want emails", f.destTube)
+	}
+
+	// the flags given take precedence over the profile
+	f = newMigrateFlags()
+	if err := f.parse([]string{"-config", path, "-from", "localhost:11300", "-from-profile", "old", "-to", "target:11300", "-tube", "emails"}); err != nil {
+		t.Fatal(err)
+	}
+	if f.from.addr != "localhost:11300" || f.from.ssh != "ops@bastion" {
+		t.Errorf("from = %s over ssh %q", f.from.addr, f.from.ssh)
+	}
+
+	for _, args := range [][]string{
+		{"-tube", "emails"},
+		{"-to", "target:11300"},
+		{"-config", path, "-to-profile", "missing", "-tube", "emails"},
+		{"-to", "target:11300", "-tube", "emails", "-states", "reserved"},
+	} {
+		if err := newMigrateFlags().parse(args); err == nil {
+			t.Errorf("migrate %v succeeded, want an error", args)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	src, dst := beanstalktest.NewServer(), beanstalktest.NewServer()
+	defer src.Close()
+	defer dst.Close()
+	src.Put("emails", []byte("ready"), 10, 0, time.Minute)
+	src.Put("emails", []byte("buried"), 20, 0, time.Minute)
+	c, err := walker.Dial(src.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.BuryJob(2, 20); err != nil {
+		t.Fatal(err)
+	}
+
+	// the target cannot bury again the buried job
+	dst.SetVersion("1.10")
+	args := []string{"-from", src.Addr, "-to", dst.Addr, "-tube", "emails", "-dest-tube", "archive"}
+	if err := runMigrate(args); err == nil || !strings.Contains(err.Error(), "-bury=false") {
+		t.Fatalf("migrate to 1.10: err = %v, want the -bury=false hint", err)
+	}
+
+	dst.SetVersion(beanstalktest.Version)
+	if err := runMigrate(args); err != nil {
+		t.Fatal(err)
+	}
+	target, err := walker.Dial(dst.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	stats, err := target.TubeStats("archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.CurrentJobsReady != 1 || stats.CurrentJobsBuried != 1 {
+		t.Errorf("archive ready/buried = %d/%d, want 1/1", stats.CurrentJobsReady, stats.CurrentJobsBuried)
+	}
+	if stats, _ := c.ServerStats(); stats.CurrentJobsReady+stats.CurrentJobsBuried != 0 {
+		t.Errorf("%d jobs left on the source", stats.CurrentJobsReady+stats.CurrentJobsBuried)
+	}
+}
+
+func TestKickFlags(t *testing.T) {
+	err := runKick([]string{"-tube", "emails", "-n", "5", "-where", "age > 1d"})
+	if _, ok := err.(usageError); !ok {
+		t.Errorf("kick -n with -where: err = %v, want a usage error", err)
+	}
+}
+
+func TestPutFlags(t *testing.T) {
+	s := beanstalktest.NewServer()
+	defer s.Close()
+	host, port, _ := net.SplitHostPort(s.Addr)
+
+	// priorities are 32-bit, a larger one would wrap around to an urgent job
+	args := []string{"-h", host, "-p", port, "-tube", "emails", "-body", "a"}
+	if _, ok := runPut(append(args, "-pri", "4294967296")).(usageError); !ok {
+		t.Error("put -pri 4294967296 isn't a usage error")
+	}
+	if err := runPut(append(args, "-pri", "4294967295")); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := walker.Dial(s.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	stats, err := c.TubeStats("emails")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalJobs != 1 || stats.CurrentJobsUrgent != 0 {
+		t.Errorf("total/urgent = %d/%d, want the job of the lowest priority only", stats.TotalJobs, stats.CurrentJobsUrgent)
+	}
+}
+
+func TestDeleteDryRun(t *testing.T) {
+	s := beanstalktest.NewServer()
+	defer s.Close()
+	s.Put("emails", []byte("a"), 10, 0, time.Minute)
+	host, port, _ := net.SplitHostPort(s.Addr)
+
+	args := []string{"-h", host, "-p", port, "-tube", "emails", "-state", "ready", "-where", "age < 1h", "-dry-run"}
+	if err := runDelete(args); err != nil {
+		t.Fatal(err)
+	}
+	c, err := walker.Dial(s.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if stats, _ := c.TubeStats("emails"); stats.CurrentJobsReady != 1 {
+		t.Errorf("ready = %d after a dry run, want 1", stats.CurrentJobsReady)
+	}
+
+	if _, ok := runDelete([]string{"-tube", "emails", "-state", "ready", "-dry-run"}).(usageError); !ok {
+		t.Error("-dry-run without -where isn't a usage error")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/kadekcipta/beanwalker/decode"
+)
+
+// Config is the optional JSON configuration file given by -config
+type Config struct {
+	// Tubes holds the settings per tube name
+	Tubes map[string]TubeConfig `json:"tubes"`
+	// Profiles holds the connection settings per profile name, picked with -profile
+	Profiles map[string]ProfileConfig `json:"profiles"`
+}
+
+// TubeConfig holds the settings of a single tube
+type TubeConfig struct {
+	// Decoder names the job body decoder, it is sniffed from the body when empty
+	Decoder string `json:"decoder"`
+}
+
+// ProfileConfig holds the connection settings of a profile, the empty ones keep the flag defaults
+type ProfileConfig struct {
+	// Host is the host or the full address, as -h
+	Host          string      `json:"host"`
+	Port          int         `json:"port"`
+	Timeout       string      `json:"timeout"`
+	SSH           string      `json:"ssh"`
+	SSHKey        string      `json:"ssh_key"`
+	SSHKnownHosts string      `json:"ssh_known_hosts"`
+	TLS           *TLSProfile `json:"tls"`
+}
+
+// TLSProfile holds the TLS settings of a profile, TLS is used as soon as the section is given
+type TLSProfile struct {
+	CA                 string `json:"ca"`
+	Cert               string `json:"cert"`
+	Key                string `json:"key"`
+	ServerName         string `json:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+// flags returns the profile settings as the values of the flags they stand for
+func (p ProfileConfig) flags() map[string]string {
+	values := map[string]string{
+		"h":               p.Host,
+		"timeout":         p.Timeout,
+		"ssh":             p.SSH,
+		"ssh-key":         p.SSHKey,
+		"ssh-known-hosts": p.SSHKnownHosts,
+	}
+	if p.Port != 0 {
+		values["p"] = strconv.Itoa(p.Port)
+	}
+	if p.TLS != nil {
+		values["tls"] = "true"
+		values["tls-ca"] = p.TLS.CA
+		values["tls-cert"] = p.TLS.Cert
+		values["tls-key"] = p.TLS.Key
+		values["tls-server-name"] = p.TLS.ServerName
+		if p.TLS.InsecureSkipVerify {
+			values["tls-insecure"] = "true"
+		}
+	}
+	return values
+}
+
+// applyProfile sets the flags not given on the command line to the settings of the profile, no profile is fine
+// The flag names are prefixed, e.g. from- for the source server of migrate, settings without a flag are skipped
+func (c *Config) applyProfile(f *flag.FlagSet, name, prefix string) error {
+	if name == "" {
+		return nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+
+	given := map[string]bool{}
+	f.Visit(func(fl *flag.Flag) {
+		given[fl.Name] = true
+	})
+	for flagName, value := range p.flags() {
+		flagName = prefix + flagName
+		if value == "" || given[flagName] || f.Lookup(flagName) == nil {
+			continue
+		}
+		if err := f.Set(flagName, value); err != nil {
+			return fmt.Errorf("profile %s: %s: %v", name, flagName, err)
+		}
+	}
+	return nil
+}
+
+// loadConfig reads the configuration file, an empty path gives the default configuration
+func loadConfig(path string) (*Config, error) {
+	config := &Config{Tubes: map[string]TubeConfig{}}
+	if path == "" {
+		return config, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, config); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	for name, tube := range config.Tubes {
+		if tube.Decoder != "" && decode.Default.Lookup(tube.Decoder) == nil {
+			return nil, fmt.Errorf("%s: tube %s: unknown decoder %q, expected one of %v", path, name, tube.Decoder, decode.Default.Names())
+		}
+	}
+
+	return config, nil
+}
+
+// decoder returns the decoder name configured for the tube, empty to sniff
+func (c *Config) decoder(tubeName string) string {
+	if c == nil {
+		return ""
+	}
+	return c.Tubes[tubeName].Decoder
+}
+
+// decodedBody returns the body decoded by the decoder of the tube, as it is when it cannot be decoded
+func (c *Config) decodedBody(tubeName string, body []byte) []byte {
+	decoded, err := decode.Default.Decode(body, c.decoder(tubeName))
+	if err != nil {
+		return body
+	}
+	return decoded.Body
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testProfiles = `{"profiles": {
+	"prod": {
+		"host": "10.0.0.1", "port": 11301, "timeout": "3s", "ssh": "ops@bastion", "ssh_key": "bastion.key",
+		"tls": {"ca": "ca.pem", "server_name": "beanstalk.internal", "insecure_skip_verify": true}
+	},
+	"staging": {"host": "10.0.0.2"}
+}}`
+
+// parseCommandFlags parses the arguments of a subcommand along with its profile
+func parseCommandFlags(t *testing.T, args ...string) *commandFlags {
+	t.Helper()
+
+	f := newCommandFlags("test")
+	if err := f.FlagSet.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	config, err := loadConfig(f.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.applyProfile(f.FlagSet, f.profile, ""); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestApplyProfile(t *testing.T) {
+	path := writeConfig(t, testProfiles)
+
+	f := parseCommandFlags(t, "-config", path, "-profile", "prod")
+	if f.addr() != "10.0.0.1:11301" || f.timeout != 3*time.Second || f.ssh != "ops@bastion" || f.sshKey != "bastion.key" {
+		t.Errorf("prod = %s in %s over ssh %q with key %q", f.addr(), f.timeout, f.ssh, f.sshKey)
+	}
+	if !f.tls || f.tlsCA != "ca.pem" || f.tlsServer != "beanstalk.internal" || !f.tlsInsecure {
+		t.Errorf("prod tls %v, ca %q, server name %q, insecure %v", f.tls, f.tlsCA, f.tlsServer, f.tlsInsecure)
+	}
+
+	// the flags given take precedence, the others come from the profile
+	f = parseCommandFlags(t, "-config", path, "-profile", "prod", "-p", "11400", "-timeout", "1s", "-tls-ca", "other.pem", "-tls-insecure=false")
+	if f.addr() != "10.0.0.1:11400" || f.timeout != time.Second || f.ssh != "ops@bastion" {
+		t.Errorf("prod with flags = %s in %s over ssh %q", f.addr(), f.timeout, f.ssh)
+	}
+	if f.tlsCA != "other.pem" || f.tlsServer != "beanstalk.internal" || f.tlsInsecure {
+		t.Errorf("prod with flags: tls ca %q, server name %q, insecure %v", f.tlsCA, f.tlsServer, f.tlsInsecure)
+	}
+
+	// the settings missing from the profile keep the defaults
+	f = parseCommandFlags(t, "-config", path, "-profile", "staging")
+	if f.addr() != "10.0.0.2:11300" || f.timeout != defaultTimeout || f.ssh != "" || f.tls {
+		t.Errorf("staging = %s in %s over ssh %q, tls %v", f.addr(), f.timeout, f.ssh, f.tls)
+	}
+
+	// no profile
+	f = parseCommandFlags(t, "-config", path, "-h", "localhost")
+	if f.addr() != "localhost:11300" || f.ssh != "" {
+		t.Errorf("without profile = %s over ssh %q", f.addr(), f.ssh)
+	}
+
+	config, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.applyProfile(newCommandFlags("test").FlagSet, "missing", ""); err == nil {
+		t.Error("unknown profile applied")
+	}
+}
+
+func TestApplyProfilePrefixed(t *testing.T) {
+	path := writeConfig(t, testProfiles)
+
+	f := newMigrateFlags()
+	err := f.parse([]string{
+		"-config", path, "-tube", "emails",
+		"-from-profile", "prod", "-from-timeout", "1s", "-from-tls-server-name", "old.internal",
+		"-to-profile", "staging", "-to-ssh", "ops@gateway",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, to := f.from, f.to
+	if from.addr != "10.0.0.1:11301" || from.timeout != time.Second || from.ssh != "ops@bastion" || from.sshKey != "bastion.key" {
+		t.Errorf("from = %s in %s over ssh %q with key %q", from.addr, from.timeout, from.ssh, from.sshKey)
+	}
+	if !from.tls || from.tlsCA != "ca.pem" || from.tlsServer != "old.internal" || !from.tlsInsecure {
+		t.Errorf("from tls %v, ca %q, server name %q, insecure %v", from.tls, from.tlsCA, from.tlsServer, from.tlsInsecure)
+	}
+	// the profile of a side leaves the other one alone
+	if to.addr != "10.0.0.2:11300" || to.timeout != defaultTimeout || to.ssh != "ops@gateway" || to.sshKey != "" || to.tls {
+		t.Errorf("to = %s in %s over ssh %q with key %q, tls %v", to.addr, to.timeout, to.ssh, to.sshKey, to.tls)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	config, err := loadConfig("")
+	if err != nil || len(config.Tubes) != 0 || len(config.Profiles) != 0 {
+		t.Errorf("default config = %+v, %v, want an empty one", config, err)
+	}
+
+	config, err = loadConfig(writeConfig(t, `{"tubes": {"emails": {"decoder": "json"}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.decoder("emails") != "json" || config.decoder("sms") != "" {
+		t.Errorf("decoders = %q and %q, want json and none", config.decoder("emails"), config.decoder("sms"))
+	}
+
+	for _, path := range []string{
+		filepath.Join(t.TempDir(), "missing.json"),
+		writeConfig(t, `{"tubes": `),
+		writeConfig(t, `{"tubes": {"emails": {"decoder": "yaml"}}}`),
+		writeConfig(t, `{"profiles": {"prod": {"port": "11300"}}}`),
+	} {
+		if _, err := loadConfig(path); err == nil {
+			t.Errorf("loadConfig(%s) succeeded, want an error", path)
+		}
+	}
+}
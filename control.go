@@ -33,4 +33,32 @@ type BufferProxy interface {
 	Clear(termbox.Attribute, termbox.Attribute)
 	SetCell(x, y int, ch rune, fg, bg termbox.Attribute)
 	WriteText(x, y int, fg, bg termbox.Attribute, s string)
+	Size() (int, int)
+	Flush() error
+}
+
+// termboxProxy draws straight to the terminal
+type termboxProxy struct{}
+
+func (termboxProxy) Clear(fg termbox.Attribute, bg termbox.Attribute) {
+	termbox.Clear(fg, bg)
+}
+
+func (termboxProxy) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	termbox.SetCell(x, y, ch, fg, bg)
+}
+
+func (termboxProxy) WriteText(x, y int, fg, bg termbox.Attribute, s string) {
+	for _, c := range s {
+		termbox.SetCell(x, y, c, fg, bg)
+		x++
+	}
+}
+
+func (termboxProxy) Size() (int, int) {
+	return termbox.Size()
+}
+
+func (termboxProxy) Flush() error {
+	return termbox.Flush()
 }
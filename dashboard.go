@@ -0,0 +1,146 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+//go:embed web/dashboard.html
+var dashboardHTML []byte
+
+// sampleHub hands the polled samples to the dashboard event streams
+type sampleHub struct {
+	subs map[chan *walker.Sample]bool
+	last *walker.Sample
+	sync.Mutex
+}
+
+func newSampleHub() *sampleHub {
+	return &sampleHub{subs: map[chan *walker.Sample]bool{}}
+}
+
+// publish sends the sample to every stream, slow streams miss it rather than hold the poll
+func (h *sampleHub) publish(sample *walker.Sample) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.last = sample
+	for ch := range h.subs {
+		select {
+		case ch <- sample:
+		default:
+		}
+	}
+}
+
+// subscribe returns the channel of the next samples along with the last one published
+func (h *sampleHub) subscribe() (chan *walker.Sample, *walker.Sample) {
+	h.Lock()
+	defer h.Unlock()
+
+	ch := make(chan *walker.Sample, 1)
+	h.subs[ch] = true
+	return ch, h.last
+}
+
+func (h *sampleHub) unsubscribe(ch chan *walker.Sample) {
+	h.Lock()
+	delete(h.subs, ch)
+	h.Unlock()
+}
+
+// sampleJSON is the sample as sent to the dashboard, stats are keyed by name
+type sampleJSON struct {
+	Time   time.Time                `json:"time"`
+	System map[string]interface{}   `json:"system"`
+	Tubes  []map[string]interface{} `json:"tubes"`
+}
+
+func newSampleJSON(sample *walker.Sample) *sampleJSON {
+	s := &sampleJSON{Time: sample.Time, System: statsJSON(sample.System), Tubes: []map[string]interface{}{}}
+	for _, t := range sample.Tubes {
+		s.Tubes = append(s.Tubes, statsJSON(t))
+	}
+	return s
+}
+
+func (s *apiServer) dashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// columns returns the column names of the grids, the dashboard mirrors them
+// The stats of the server missing from the grids are appended as the user interface discovers them
+func (s *apiServer) columns(r *http.Request) (interface{}, error) {
+	sample, err := s.client.Sample()
+	if err != nil {
+		return nil, err
+	}
+
+	system := append(copyColumns(sysStatsColumns), unknownColumns(sysStatsColumns, sample.System.Raw)...)
+	tubes := copyColumns(tubeStatsColumns)
+	for _, stats := range sample.Tubes {
+		tubes = append(tubes, unknownColumns(tubes, stats.Raw)...)
+	}
+
+	names := func(columns []GridColumn) []string {
+		list := []string{}
+		for _, col := range columns {
+			list = append(list, col.Name)
+		}
+		return list
+	}
+	return map[string][]string{"system": names(system), "tubes": names(tubes)}, nil
+}
+
+// events streams the polled samples as server-sent events
+func (s *apiServer) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	ch, last := s.hub.subscribe()
+	defer s.hub.unsubscribe(ch)
+
+	send := func(sample *walker.Sample) error {
+		b, err := json.Marshal(newSampleJSON(sample))
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: sample\ndata: %s\n\n", b); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	if last != nil {
+		if send(last) != nil {
+			return
+		}
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case sample := <-ch:
+			if send(sample) != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package decode turns job bodies into readable text.
+// Decoders are kept in a registry and selected by name or by sniffing the body, payloads unwrapped by a
+// wrapper decoder such as gzip are sniffed and decoded again.
+package decode
+
+import (
+	"fmt"
+	"sort"
+)
+
+// maxDepth bounds the number of nested decoders applied to a single body
+const maxDepth = 8
+
+// Decoder decodes one body format
+type Decoder struct {
+	Name string
+	// Match reports whether the body looks encoded in the decoder format
+	Match func(body []byte) bool
+	// Decode returns the decoded body
+	Decode func(body []byte) ([]byte, error)
+	// Wrapper decoders unwrap a payload which is decoded again, e.g. gzip
+	Wrapper bool
+}
+
+// Decoded is the outcome of decoding a body
+type Decoded struct {
+	// Chain names the applied decoders, outermost first
+	Chain []string
+	Body  []byte
+}
+
+// Registry keeps the decoders in sniffing order
+type Registry struct {
+	decoders []*Decoder
+}
+
+// NewRegistry returns a registry sniffing the decoders in the given order
+func NewRegistry(decoders ...*Decoder) *Registry {
+	r := &Registry{}
+	for _, d := range decoders {
+		r.Register(d)
+	}
+	return r
+}
+
+// Default is the registry of the builtin decoders, hex dump being the fallback
+var Default = NewRegistry(Gzip, Zlib, JSON, PHP, MsgPack, Base64, Text, Hex)
+
+// Register adds the decoder, it replaces the decoder of the same name in place
+// New decoders are sniffed before the last registered one, so the fallback stays last
+func (r *Registry) Register(d *Decoder) {
+	for i, e := range r.decoders {
+		if e.Name == d.Name {
+			r.decoders[i] = d
+			return
+		}
+	}
+	if n := len(r.decoders); n > 0 && r.decoders[n-1] == Hex {
+		r.decoders = append(r.decoders[:n-1], d, Hex)
+		return
+	}
+	r.decoders = append(r.decoders, d)
+}
+
+// Lookup returns the decoder of the name, nil when unknown
+func (r *Registry) Lookup(name string) *Decoder {
+	for _, d := range r.decoders {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// Names returns the sorted names of the registered decoders
+func (r *Registry) Names() []string {
+	names := []string{}
+	for _, d := range r.decoders {
+		names = append(names, d.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Sniff returns the first decoder matching the body, nil when none does
+func (r *Registry) Sniff(body []byte) *Decoder {
+	for _, d := range r.decoders {
+		if d.Match(body) {
+			return d
+		}
+	}
+	return nil
+}
+
+// Decode decodes the body with the named decoder, or the sniffed one when name is empty
+// The decoded part is returned along with the error when a nested decoder fails
+func (r *Registry) Decode(body []byte, name string) (*Decoded, error) {
+	res := &Decoded{Body: body}
+
+	d := r.Sniff(body)
+	if name != "" {
+		if d = r.Lookup(name); d == nil {
+			return res, fmt.Errorf("unknown decoder %q", name)
+		}
+	}
+
+	for depth := 0; d != nil && depth < maxDepth; depth++ {
+		out, err := d.Decode(res.Body)
+		if err != nil {
+			return res, fmt.Errorf("%s: %v", d.Name, err)
+		}
+		res.Chain = append(res.Chain, d.Name)
+		res.Body = out
+		if !d.Wrapper {
+			break
+		}
+		d = r.Sniff(out)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,161 @@
+package decode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxDecompressed bounds the size of a decompressed payload, 256 times the default max-job-size
+var MaxDecompressed int64 = 256 * 65535
+
+// sniffSize is the number of bytes decompressed to sniff a stream
+const sniffSize = 512
+
+// readLimited reads the decompressed stream, failing past MaxDecompressed
+func readLimited(r io.Reader) ([]byte, error) {
+	out, err := ioutil.ReadAll(io.LimitReader(r, MaxDecompressed+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > MaxDecompressed {
+		return nil, fmt.Errorf("decompressed payload larger than %d bytes", MaxDecompressed)
+	}
+	return out, nil
+}
+
+// Gzip decompresses gzip payloads
+var Gzip = &Decoder{
+	Name: "gzip",
+	Match: func(body []byte) bool {
+		return len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b
+	},
+	Decode: func(body []byte) ([]byte, error) {
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return readLimited(r)
+	},
+	Wrapper: true,
+}
+
+// Zlib decompresses zlib payloads, e.g. from PHP gzcompress()
+var Zlib = &Decoder{
+	Name: "zlib",
+	Match: func(body []byte) bool {
+		// compression method 8 and the header checksum, text can match both so the start of the stream is
+		// checked too
+		if len(body) < 3 || body[0]&0x0f != 8 || (uint(body[0])<<8|uint(body[1]))%31 != 0 {
+			return false
+		}
+		r, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return false
+		}
+		defer r.Close()
+		_, err = ioutil.ReadAll(io.LimitReader(r, sniffSize))
+		return err == nil
+	},
+	Decode:  decodeZlib,
+	Wrapper: true,
+}
+
+func decodeZlib(body []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimited(r)
+}
+
+// JSON pretty prints JSON objects and arrays
+var JSON = &Decoder{
+	Name: "json",
+	Match: func(body []byte) bool {
+		trimmed := bytes.TrimSpace(body)
+		if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+			return false
+		}
+		return json.Valid(trimmed)
+	},
+	Decode: func(body []byte) ([]byte, error) {
+		out := &bytes.Buffer{}
+		if err := json.Indent(out, bytes.TrimSpace(body), "", "  "); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	},
+}
+
+// Base64 decodes standard and URL base64, with or without padding
+// Only payloads decoding into text or another known format are sniffed as base64
+var Base64 = &Decoder{
+	Name: "base64",
+	Match: func(body []byte) bool {
+		out, err := decodeBase64(body)
+		if err != nil || len(bytes.TrimSpace(body)) < 8 {
+			return false
+		}
+		for _, d := range []*Decoder{Gzip, Zlib, JSON, PHP, MsgPack, Text} {
+			if d.Match(out) {
+				return true
+			}
+		}
+		return false
+	},
+	Decode:  decodeBase64,
+	Wrapper: true,
+}
+
+func decodeBase64(body []byte) ([]byte, error) {
+	s := string(bytes.TrimSpace(body))
+	var err error
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		var out []byte
+		if out, err = enc.DecodeString(s); err == nil {
+			return out, nil
+		}
+	}
+	return nil, err
+}
+
+// Text shows printable UTF-8 bodies as they are
+var Text = &Decoder{
+	Name: "text",
+	Match: func(body []byte) bool {
+		if !utf8.Valid(body) {
+			return false
+		}
+		for _, r := range string(body) {
+			if !unicode.IsPrint(r) && r != '\n' && r != '\r' && r != '\t' {
+				return false
+			}
+		}
+		return true
+	},
+	Decode: func(body []byte) ([]byte, error) {
+		return body, nil
+	},
+}
+
+// Hex dumps any body, it is the fallback of the default registry
+var Hex = &Decoder{
+	Name: "hex",
+	Match: func(body []byte) bool {
+		return true
+	},
+	Decode: func(body []byte) ([]byte, error) {
+		return []byte(hex.Dump(body)), nil
+	},
+}
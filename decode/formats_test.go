@@ -0,0 +1,151 @@
+package decode
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+)
+
+func compress(t *testing.T, newWriter func(io.Writer) io.WriteCloser, body []byte) []byte {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	w := newWriter(out)
+	if _, err := w.Write(body); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func gzipWriter(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+func zlibWriter(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }
+
+func TestDecodeNested(t *testing.T) {
+	body := compress(t, gzipWriter, []byte(base64.StdEncoding.EncodeToString(compress(t, zlibWriter, []byte(`{"id":1}`)))))
+
+	res, err := Default.Decode(body, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(res.Chain, " "); got != "gzip base64 zlib json" {
+		t.Errorf("chain = %q", got)
+	}
+	if string(res.Body) != "{\n  \"id\": 1\n}" {
+		t.Errorf("body = %q", res.Body)
+	}
+}
+
+func TestDecompressLimit(t *testing.T) {
+	bomb := make([]byte, MaxDecompressed+1)
+
+	for _, tt := range []struct {
+		d         *Decoder
+		newWriter func(io.Writer) io.WriteCloser
+	}{
+		{Gzip, gzipWriter},
+		{Zlib, zlibWriter},
+	} {
+		body := compress(t, tt.newWriter, bomb)
+		if !tt.d.Match(body) {
+			t.Errorf("%s: the stream doesn't match", tt.d.Name)
+		}
+		if _, err := tt.d.Decode(body); err == nil {
+			t.Errorf("%s: %d decompressed bytes, want an error", tt.d.Name, len(bomb))
+		}
+		if _, err := tt.d.Decode(compress(t, tt.newWriter, bomb[:MaxDecompressed])); err != nil {
+			t.Errorf("%s: payload at the limit: %v", tt.d.Name, err)
+		}
+	}
+}
+
+func TestZlibMatch(t *testing.T) {
+	body := compress(t, zlibWriter, []byte("hello"))
+	if !Zlib.Match(body) {
+		t.Error("zlib stream doesn't match")
+	}
+	// "x^" passes the header checksum, the stream doesn't decompress
+	if Zlib.Match([]byte("x^ plain text")) {
+		t.Error("text with a zlib header matches")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	for _, tt := range []struct {
+		body  string
+		match bool
+	}{
+		{`{"id":1,"tags":["a"]}`, true},
+		{"  [1, 2]\n", true},
+		{`"text"`, false},
+		{`42`, false},
+		{`{"id":`, false},
+		{`{"id":1} trailing`, false},
+		{`{'id':1}`, false},
+		{``, false},
+	} {
+		if got := JSON.Match([]byte(tt.body)); got != tt.match {
+			t.Errorf("JSON.Match(%q) = %v, want %v", tt.body, got, tt.match)
+		}
+	}
+
+	out, err := JSON.Decode([]byte(" {\"id\":1,\"tags\":[\"a\"]}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "{\n  \"id\": 1,\n  \"tags\": [\n    \"a\"\n  ]\n}" {
+		t.Errorf("decoded %q", out)
+	}
+	if _, err := JSON.Decode([]byte(`{"id":`)); err == nil {
+		t.Error("truncated JSON decoded")
+	}
+}
+
+func TestBase64(t *testing.T) {
+	payload := []byte(`{"id":1,"note":"ünïcode?>"}`)
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		body := []byte(enc.EncodeToString(payload) + "\n")
+		res, err := Default.Decode(body, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(res.Chain, " "); got != "base64 json" {
+			t.Errorf("%s: chain = %q, want base64 json", body, got)
+		}
+	}
+
+	// gzip wrapped into URL safe base64
+	body := base64.RawURLEncoding.EncodeToString(compress(t, gzipWriter, []byte("plain text job")))
+	res, err := Default.Decode([]byte(body), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(res.Chain, " "); got != "base64 gzip text" || string(res.Body) != "plain text job" {
+		t.Errorf("chain = %q, body %q", got, res.Body)
+	}
+
+	for _, body := range []string{
+		// too short to tell from a word
+		"aGk=",
+		// decodes into binary
+		"password",
+		"not base64!",
+	} {
+		if Base64.Match([]byte(body)) {
+			t.Errorf("%q sniffed as base64", body)
+		}
+	}
+	if _, err := Base64.Decode([]byte("not base64!")); err == nil {
+		t.Error("invalid base64 decoded")
+	}
+	// a payload cut in the middle of a 4 characters group is refused by every encoding
+	if _, err := Base64.Decode([]byte("eyJpZCI6M")); err == nil {
+		t.Error("base64 cut in the middle of a quantum decoded")
+	}
+}
@@ -0,0 +1,263 @@
+package decode
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// maxNesting bounds the nesting of arrays and maps in structured payloads
+const maxNesting = 64
+
+var errTruncated = errors.New("unexpected end of data")
+
+// MsgPack renders MessagePack maps and arrays as JSON
+var MsgPack = &Decoder{
+	Name: "msgpack",
+	Match: func(body []byte) bool {
+		if len(body) == 0 {
+			return false
+		}
+		// only containers are sniffed, a lone scalar is indistinguishable from text
+		b := body[0]
+		if !(b >= 0x80 && b <= 0x9f) && !(b >= 0xdc && b <= 0xdf) {
+			return false
+		}
+		_, err := decodeMsgPack(body)
+		return err == nil
+	},
+	Decode: func(body []byte) ([]byte, error) {
+		v, err := decodeMsgPack(body)
+		if err != nil {
+			return nil, err
+		}
+		return render(v)
+	},
+}
+
+func decodeMsgPack(body []byte) (interface{}, error) {
+	r := &msgpackReader{b: body}
+	v, err := r.value(0)
+	if err != nil {
+		return nil, err
+	}
+	if r.pos != len(body) {
+		return nil, fmt.Errorf("%d trailing bytes", len(body)-r.pos)
+	}
+	return v, nil
+}
+
+type msgpackReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *msgpackReader) read(n int) ([]byte, error) {
+	if n < 0 || len(r.b)-r.pos < n {
+		return nil, errTruncated
+	}
+	b := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// uint reads a big endian unsigned integer of n bytes
+func (r *msgpackReader) uint(n int) (uint64, error) {
+	b, err := r.read(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// length reads a length of n bytes, lengths beyond the remaining data are rejected
+func (r *msgpackReader) length(n int) (int, error) {
+	l, err := r.uint(n)
+	if err != nil {
+		return 0, err
+	}
+	if l > uint64(len(r.b)-r.pos) {
+		return 0, errTruncated
+	}
+	return int(l), nil
+}
+
+func (r *msgpackReader) value(depth int) (interface{}, error) {
+	if depth > maxNesting {
+		return nil, errors.New("nesting too deep")
+	}
+	head, err := r.read(1)
+	if err != nil {
+		return nil, err
+	}
+	b := head[0]
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b >= 0x80 && b <= 0x8f:
+		return r.mapValue(int(b&0x0f), depth)
+	case b >= 0x90 && b <= 0x9f:
+		return r.arrayValue(int(b&0x0f), depth)
+	case b >= 0xa0 && b <= 0xbf:
+		return r.str(int(b & 0x1f))
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.length(1 << (b - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return r.read(n)
+
+	case 0xc7, 0xc8, 0xc9:
+		n, err := r.length(1 << (b - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return r.ext(n)
+
+	case 0xca:
+		v, err := r.uint(4)
+		return finite(float64(math.Float32frombits(uint32(v)))), err
+	case 0xcb:
+		v, err := r.uint(8)
+		return finite(math.Float64frombits(v)), err
+
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return r.uint(1 << (b - 0xcc))
+
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (b - 0xd0)
+		v, err := r.uint(n)
+		if err != nil {
+			return nil, err
+		}
+		// sign extend from n bytes
+		shift := uint(64 - 8*n)
+		return int64(v<<shift) >> shift, nil
+
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return r.ext(1 << (b - 0xd4))
+
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.length(1 << (b - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return r.str(n)
+
+	case 0xdc, 0xdd:
+		n, err := r.length(2 << (b - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return r.arrayValue(n, depth)
+
+	case 0xde, 0xdf:
+		n, err := r.length(2 << (b - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return r.mapValue(n, depth)
+	}
+
+	return nil, fmt.Errorf("invalid type byte 0x%02x", b)
+}
+
+func (r *msgpackReader) str(n int) (interface{}, error) {
+	b, err := r.read(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// ext renders the extension type with its data as hex, timestamps are shown as seconds and nanoseconds
+func (r *msgpackReader) ext(n int) (interface{}, error) {
+	t, err := r.read(1)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.read(n)
+	if err != nil {
+		return nil, err
+	}
+
+	o := newObject()
+	o.set("ext", int8(t[0]))
+	if int8(t[0]) == -1 && (n == 4 || n == 8 || n == 12) {
+		var sec int64
+		var nsec uint32
+		switch n {
+		case 4:
+			sec = int64(binary.BigEndian.Uint32(data))
+		case 8:
+			v := binary.BigEndian.Uint64(data)
+			nsec, sec = uint32(v>>34), int64(v&0x3ffffffff)
+		case 12:
+			nsec, sec = binary.BigEndian.Uint32(data), int64(binary.BigEndian.Uint64(data[4:]))
+		}
+		o.set("seconds", sec)
+		o.set("nanoseconds", nsec)
+		return o, nil
+	}
+	o.set("data", hex.EncodeToString(data))
+	return o, nil
+}
+
+func (r *msgpackReader) arrayValue(n, depth int) (interface{}, error) {
+	a := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func (r *msgpackReader) mapValue(n, depth int) (interface{}, error) {
+	o := newObject()
+	for i := 0; i < n; i++ {
+		k, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := r.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		o.set(key, v)
+	}
+	return o, nil
+}
+
+// finite keeps NaN and infinities as text, JSON has no literal for them
+func finite(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Sprint(f)
+	}
+	return f
+}
@@ -0,0 +1,115 @@
+package decode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+// compact returns the rendered JSON on a single line
+func compact(t *testing.T, b []byte) string {
+	t.Helper()
+
+	out := &bytes.Buffer{}
+	if err := json.Compact(out, b); err != nil {
+		t.Fatalf("%s: %v", b, err)
+	}
+	return out.String()
+}
+
+func float64Bytes(f float64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, math.Float64bits(f))
+	return b
+}
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+var msgpackTests = []struct {
+	name string
+	body []byte
+	want string
+}{
+	{"fixmap", concat([]byte{0x84, 0xa2}, []byte("id"), []byte{0x01, 0xa4}, []byte("tags"), []byte{0x92, 0xa1, 'a', 0xa1, 'b', 0xa2}, []byte("ok"), []byte{0xc3, 0xa1, 'n', 0xc0}),
+		`{"id":1,"tags":["a","b"],"ok":true,"n":null}`},
+	{"integers", []byte{0x96, 0xcc, 0xff, 0xcd, 0x01, 0x00, 0xd0, 0x80, 0xd1, 0xff, 0x00, 0xe0, 0x7f}, `[255,256,-128,-256,-32,127]`},
+	{"floats", concat([]byte{0x92, 0xcb}, float64Bytes(1.5), []byte{0xca, 0x7f, 0xc0, 0x00, 0x00}), `[1.5,"NaN"]`},
+	{"str8", concat([]byte{0x91, 0xd9, 0x05}, []byte("hello")), `["hello"]`},
+	{"bin8", []byte{0x91, 0xc4, 0x02, 0x01, 0x02}, `["AQI="]`},
+	{"array16", []byte{0xdc, 0x00, 0x02, 0x07, 0xc2}, `[7,false]`},
+	{"map16 with integer key", []byte{0xde, 0x00, 0x01, 0x01, 0xa1, 'x'}, `{"1":"x"}`},
+	{"timestamp", []byte{0x91, 0xd6, 0xff, 0x59, 0x68, 0x2f, 0x00}, `[{"ext":-1,"seconds":1500000000,"nanoseconds":0}]`},
+	{"ext", []byte{0x91, 0xd4, 0x05, 0xab}, `[{"ext":5,"data":"ab"}]`},
+	{"ext8", []byte{0x91, 0xc7, 0x02, 0x07, 0xca, 0xfe}, `[{"ext":7,"data":"cafe"}]`},
+}
+
+func TestMsgPackDecode(t *testing.T) {
+	for _, tt := range msgpackTests {
+		if !MsgPack.Match(tt.body) {
+			t.Errorf("%s: doesn't match", tt.name)
+		}
+		res, err := Default.Decode(tt.body, "")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(res.Chain) != 1 || res.Chain[0] != "msgpack" {
+			t.Errorf("%s: chain = %v, want msgpack", tt.name, res.Chain)
+		}
+		if got := compact(t, res.Body); got != tt.want {
+			t.Errorf("%s: decoded %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMsgPackMalformed(t *testing.T) {
+	// every truncation of a container is incomplete
+	for _, tt := range msgpackTests {
+		for i := 0; i < len(tt.body); i++ {
+			if _, err := decodeMsgPack(tt.body[:i]); err == nil {
+				t.Errorf("%s truncated to %d bytes: decoded, want an error", tt.name, i)
+			}
+		}
+	}
+
+	deep := append(bytes.Repeat([]byte{0x91}, maxNesting+2), 0xc0)
+	for _, tt := range []struct {
+		name string
+		body []byte
+	}{
+		{"trailing bytes", []byte{0x91, 0x01, 0x02}},
+		{"array32 beyond the data", []byte{0xdd, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"map32 beyond the data", []byte{0xdf, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"str32 beyond the data", []byte{0x91, 0xdb, 0xff, 0xff, 0xff, 0xff, 'a'}},
+		{"bin32 beyond the data", []byte{0x91, 0xc6, 0x7f, 0xff, 0xff, 0xff, 'a'}},
+		{"ext32 beyond the data", []byte{0x91, 0xc9, 0x00, 0x00, 0x00, 0x10, 0x01, 'a'}},
+		{"fixarray beyond the data", []byte{0x9f, 0x01}},
+		{"invalid type", []byte{0x91, 0xc1}},
+		{"nesting", deep},
+	} {
+		if _, err := decodeMsgPack(tt.body); err == nil {
+			t.Errorf("%s: decoded, want an error", tt.name)
+		}
+		if MsgPack.Match(tt.body) {
+			t.Errorf("%s: matches", tt.name)
+		}
+	}
+}
+
+func TestMsgPackMatch(t *testing.T) {
+	for _, body := range [][]byte{
+		nil,
+		[]byte("hello"),
+		// a lone scalar
+		{0xcc, 0x01},
+		{0x85, 0x00},
+	} {
+		if MsgPack.Match(body) {
+			t.Errorf("%q matches", body)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package decode
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// phpPrefix matches the start of a serialize() payload
+var phpPrefix = regexp.MustCompile(`^(N;|b:[01];|i:-?\d+;|d:[^;]+;|s:\d+:"|a:\d+:\{|O:\d+:"|C:\d+:"|E:\d+:")`)
+
+// PHP renders payloads of PHP serialize(), as used by Laravel and Pheanstalk queues, as JSON
+// Objects are rendered with their class name under the __class key
+var PHP = &Decoder{
+	Name: "php",
+	Match: func(body []byte) bool {
+		if !phpPrefix.Match(body) {
+			return false
+		}
+		_, err := decodePHP(body)
+		return err == nil
+	},
+	Decode: func(body []byte) ([]byte, error) {
+		v, err := decodePHP(body)
+		if err != nil {
+			return nil, err
+		}
+		return render(v)
+	},
+}
+
+func decodePHP(body []byte) (interface{}, error) {
+	p := &phpParser{b: bytes.TrimSpace(body)}
+	v, err := p.value(0)
+	if err != nil {
+		return nil, fmt.Errorf("offset %d: %v", p.pos, err)
+	}
+	if p.pos != len(p.b) {
+		return nil, fmt.Errorf("offset %d: trailing data", p.pos)
+	}
+	return v, nil
+}
+
+type phpParser struct {
+	b   []byte
+	pos int
+}
+
+func (p *phpParser) expect(c byte) error {
+	if p.pos >= len(p.b) {
+		return errTruncated
+	}
+	if p.b[p.pos] != c {
+		return fmt.Errorf("expected %q, found %q", c, p.b[p.pos])
+	}
+	p.pos++
+	return nil
+}
+
+// until returns the text up to the delimiter and skips the delimiter
+func (p *phpParser) until(c byte) (string, error) {
+	i := bytes.IndexByte(p.b[p.pos:], c)
+	if i < 0 {
+		return "", errTruncated
+	}
+	s := string(p.b[p.pos : p.pos+i])
+	p.pos += i + 1
+	return s, nil
+}
+
+func (p *phpParser) int(delim byte) (int, error) {
+	s, err := p.until(delim)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(s)
+}
+
+// count reads the number of elements of an array or an object
+func (p *phpParser) count() (int, error) {
+	n, err := p.int(':')
+	if err == nil && n < 0 {
+		err = fmt.Errorf("invalid count %d", n)
+	}
+	return n, err
+}
+
+// quoted reads a double quoted string of n bytes, the length isn't bounded by quotes
+func (p *phpParser) quoted(n int) (string, error) {
+	if err := p.expect('"'); err != nil {
+		return "", err
+	}
+	if n < 0 || len(p.b)-p.pos < n {
+		return "", errTruncated
+	}
+	s := string(p.b[p.pos : p.pos+n])
+	p.pos += n
+	return s, p.expect('"')
+}
+
+func (p *phpParser) value(depth int) (interface{}, error) {
+	if depth > maxNesting {
+		return nil, fmt.Errorf("nesting too deep")
+	}
+	if len(p.b)-p.pos < 2 {
+		return nil, errTruncated
+	}
+	t := p.b[p.pos]
+	p.pos++
+
+	if t == 'N' {
+		return nil, p.expect(';')
+	}
+	if err := p.expect(':'); err != nil {
+		return nil, err
+	}
+
+	switch t {
+	case 'b':
+		n, err := p.int(';')
+		return n != 0, err
+
+	case 'i':
+		s, err := p.until(';')
+		if err != nil {
+			return nil, err
+		}
+		return strconv.ParseInt(s, 10, 64)
+
+	case 'd':
+		s, err := p.until(';')
+		if err != nil {
+			return nil, err
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, err
+		}
+		return finite(f), nil
+
+	case 's', 'E':
+		n, err := p.int(':')
+		if err != nil {
+			return nil, err
+		}
+		s, err := p.quoted(n)
+		if err != nil {
+			return nil, err
+		}
+		return s, p.expect(';')
+
+	case 'r', 'R':
+		n, err := p.int(';')
+		return fmt.Sprintf("&%d", n), err
+
+	case 'a':
+		n, err := p.count()
+		if err != nil {
+			return nil, err
+		}
+		return p.array(n, depth)
+
+	case 'O', 'C':
+		n, err := p.int(':')
+		if err != nil {
+			return nil, err
+		}
+		class, err := p.quoted(n)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(':'); err != nil {
+			return nil, err
+		}
+		if t == 'C' {
+			return p.custom(class)
+		}
+		n, err = p.count()
+		if err != nil {
+			return nil, err
+		}
+		return p.object(class, n, depth)
+	}
+
+	return nil, fmt.Errorf("invalid type %q", t)
+}
+
+// array returns a list when the keys are 0..n-1 in order, an object otherwise
+func (p *phpParser) array(n, depth int) (interface{}, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	o := newObject()
+	list := []interface{}{}
+	for i := 0; i < n; i++ {
+		k, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if list != nil && k == int64(i) {
+			list = append(list, v)
+		} else {
+			list = nil
+		}
+		o.set(fmt.Sprint(k), v)
+	}
+	if err := p.expect('}'); err != nil {
+		return nil, err
+	}
+	if list != nil {
+		return list, nil
+	}
+	return o, nil
+}
+
+func (p *phpParser) object(class string, n, depth int) (interface{}, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	o := newObject()
+	o.set("__class", class)
+	for i := 0; i < n; i++ {
+		k, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := p.value(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		o.set(propertyName(fmt.Sprint(k)), v)
+	}
+	return o, p.expect('}')
+}
+
+// custom keeps the payload of classes implementing Serializable as it is
+func (p *phpParser) custom(class string) (interface{}, error) {
+	n, err := p.int(':')
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+	if n < 0 || len(p.b)-p.pos < n {
+		return nil, errTruncated
+	}
+	data := string(p.b[p.pos : p.pos+n])
+	p.pos += n
+
+	o := newObject()
+	o.set("__class", class)
+	o.set("data", data)
+	return o, p.expect('}')
+}
+
+// propertyName strips the visibility marker of protected (\0*\0name) and private (\0Class\0name) properties
+func propertyName(name string) string {
+	if strings.HasPrefix(name, "\x00") {
+		if i := strings.LastIndexByte(name, 0); i > 0 {
+			return name[i+1:]
+		}
+	}
+	return name
+}
@@ -0,0 +1,124 @@
+package decode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// sendEmail is serialize() of a Laravel job with a protected and a private property and a model identifier
+const sendEmail = `O:18:"App\Jobs\SendEmail":3:{s:7:"` + "\x00*\x00" + `user";O:45:"Illuminate\Contracts\Database\ModelIdentifier":2:{s:5:"class";s:8:"App\User";s:2:"id";i:42;}s:26:"` + "\x00App\\Jobs\\SendEmail\x00" + `mailer";s:4:"smtp";s:5:"queue";N;}`
+
+var phpTests = []struct {
+	name string
+	body string
+	want string
+}{
+	{"list", `a:5:{i:0;N;i:1;b:1;i:2;i:-42;i:3;d:0.5;i:4;s:6:"héllo";}`, `[null,true,-42,0.5,"héllo"]`},
+	{"associative array", `a:2:{s:4:"name";s:3:"Ada";i:7;a:0:{}}`, `{"name":"Ada","7":[]}`},
+	{"keys out of order", `a:2:{i:1;s:1:"b";i:0;s:1:"a";}`, `{"1":"b","0":"a"}`},
+	{"string with quotes", `s:9:"say "hi";";`, `"say \"hi\";"`},
+	{"infinity", `a:1:{i:0;d:INF;}`, `["+Inf"]`},
+	{"reference", `a:2:{i:0;a:0:{}i:1;r:2;}`, `[[],"&2"]`},
+	{"object", sendEmail, `{"__class":"App\\Jobs\\SendEmail","user":{"__class":"Illuminate\\Contracts\\Database\\ModelIdentifier","class":"App\\User","id":42},"mailer":"smtp","queue":null}`},
+	{"serializable", `C:11:"ArrayObject":21:{x:i:0;a:0:{};m:a:0:{}}`, `{"__class":"ArrayObject","data":"x:i:0;a:0:{};m:a:0:{}"}`},
+	{"enum", `E:18:"App\Status:Pending";`, `"App\\Status:Pending"`},
+}
+
+func TestPHPDecode(t *testing.T) {
+	for _, tt := range phpTests {
+		if !PHP.Match([]byte(tt.body)) {
+			t.Errorf("%s: doesn't match", tt.name)
+		}
+		res, err := Default.Decode([]byte(tt.body), "")
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(res.Chain) != 1 || res.Chain[0] != "php" {
+			t.Errorf("%s: chain = %v, want php", tt.name, res.Chain)
+		}
+		if got := compact(t, res.Body); got != tt.want {
+			t.Errorf("%s: decoded %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPHPLaravelPayload(t *testing.T) {
+	// Laravel wraps the serialized job into JSON
+	payload, err := json.Marshal(map[string]interface{}{
+		"displayName": `App\Jobs\SendEmail`,
+		"job":         `Illuminate\Queue\CallQueuedHandler@call`,
+		"maxTries":    3,
+		"data":        map[string]string{"commandName": `App\Jobs\SendEmail`, "command": sendEmail},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Default.Decode(payload, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(res.Chain, " ") != "json" {
+		t.Errorf("chain = %v, want json", res.Chain)
+	}
+
+	var job struct {
+		Data struct{ Command string }
+	}
+	if err := json.Unmarshal(res.Body, &job); err != nil {
+		t.Fatal(err)
+	}
+	command, err := Default.Decode([]byte(job.Data.Command), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(command.Body), `"mailer": "smtp"`) {
+		t.Errorf("command = %s, want the private mailer property", command.Body)
+	}
+
+	// Pheanstalk and older Laravel versions put the serialized payload as it is
+	if d := Default.Sniff([]byte(sendEmail)); d != PHP {
+		t.Errorf("serialized job sniffed as %v, want php", d.Name)
+	}
+}
+
+func TestPHPMalformed(t *testing.T) {
+	// every truncation is incomplete
+	for _, tt := range phpTests {
+		for i := 0; i < len(tt.body); i++ {
+			if _, err := decodePHP([]byte(tt.body[:i])); err == nil {
+				t.Errorf("%s truncated to %d bytes: decoded, want an error", tt.name, i)
+			}
+		}
+	}
+
+	for _, body := range []string{
+		`s:10:"abc";`,
+		`s:-1:"";`,
+		`s:x:"abc";`,
+		`s:3:"abc"`,
+		`s:3:"abcd";`,
+		`a:-1:{}`,
+		`a:2:{i:0;i:1;}`,
+		`a:1:{i:0;i:1;i:2;i:3;}`,
+		`O:3:"Foo":-1:{}`,
+		`O:3:"Foo":1:{s:1:"a";}`,
+		`C:3:"Foo":99:{x}`,
+		`C:3:"Foo":-1:{}`,
+		`i:1;trailing`,
+		`i:1.5;`,
+		`d:abc;`,
+		`x:1;`,
+		`N`,
+		strings.Repeat(`a:1:{i:0;`, maxNesting+2) + `N;` + strings.Repeat(`}`, maxNesting+2),
+	} {
+		if v, err := decodePHP([]byte(body)); err == nil {
+			t.Errorf("%q decoded as %s, want an error", body, fmt.Sprint(v))
+		}
+		if PHP.Match([]byte(body)) {
+			t.Errorf("%q matches", body)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+// ValueFormat tells how a stat value is written in humanized mode
+type ValueFormat int
+
+const (
+	// FormatSI abbreviates with SI suffixes, e.g. 1.2k
+	FormatSI ValueFormat = iota
+	// FormatPlain keeps the value as returned by the server
+	FormatPlain
+	// FormatThousands groups the digits, e.g. 8,734,521
+	FormatThousands
+	// FormatDuration writes seconds as the two most significant units, e.g. 3h12m
+	FormatDuration
+	// FormatBytes writes sizes with binary suffixes, e.g. 64KiB
+	FormatBytes
+	// FormatPercent writes the value as a percentage of its base stat
+	FormatPercent
+)
+
+// statFormats tells how the stats are written in humanized mode
+// Counters not listed are grouped by thousands when cumulative, abbreviated otherwise
+var statFormats = map[string]ValueFormat{
+	"name":            FormatPlain,
+	"tube":            FormatPlain,
+	"state":           FormatPlain,
+	"hostname":        FormatPlain,
+	"version":         FormatPlain,
+	"id":              FormatPlain,
+	"os":              FormatPlain,
+	"platform":        FormatPlain,
+	"pid":             FormatPlain,
+	"pri":             FormatPlain,
+	"file":            FormatPlain,
+	"draining":        FormatPlain,
+	"uptime":          FormatDuration,
+	"pause":           FormatDuration,
+	"pause-time-left": FormatDuration,
+	"age":             FormatDuration,
+	"delay":           FormatDuration,
+	"ttr":             FormatDuration,
+	"time-left":       FormatDuration,
+	"max-job-size":    FormatBytes,
+	"binlog-max-size": FormatBytes,
+	"rusage-utime":    FormatPercent,
+	"rusage-stime":    FormatPercent,
+}
+
+// percentBases names the stat a percentage stat is relative to
+var percentBases = map[string]string{
+	"rusage-utime": "uptime",
+	"rusage-stime": "uptime",
+}
+
+// statFormat returns the humanized format of the stat
+func statFormat(key string) ValueFormat {
+	if f, ok := statFormats[key]; ok {
+		return f
+	}
+	if strings.HasPrefix(key, "cmd-") || strings.HasPrefix(key, "total-") || strings.HasPrefix(key, "binlog-") {
+		return FormatThousands
+	}
+	return FormatSI
+}
+
+// Formatter turns typed stat values into grid text
+type Formatter struct {
+	// Humanize writes the values by their stat format instead of as returned by the server
+	Humanize bool
+}
+
+// Row returns the formatted stats in the order of the columns, missing stats are left empty
+func (f Formatter) Row(columns []GridColumn, stats interface{}) []string {
+	values := walker.FieldMap(stats)
+	row := []string{}
+	for _, col := range columns {
+		row = append(row, f.Format(col.Name, values))
+	}
+	return row
+}
+
+// Format returns the text of the stat among the values, empty when missing
+// Durations are written in seconds unless humanized
+func (f Formatter) Format(key string, values map[string]interface{}) string {
+	v, ok := values[key]
+	if !ok {
+		return ""
+	}
+	if !f.Humanize {
+		return rawValue(v)
+	}
+
+	format := statFormat(key)
+	if format == FormatPlain {
+		return rawValue(v)
+	}
+	n, ok := number(v)
+	if !ok {
+		return rawValue(v)
+	}
+	if format == FormatPercent {
+		base, ok := number(values[percentBases[key]])
+		if !ok || base == 0 {
+			return rawValue(v)
+		}
+		return strconv.FormatFloat(n/base*100, 'f', 1, 64) + "%"
+	}
+	return formatNumber(format, n)
+}
+
+// Delta returns the signed difference of numeric stat values, empty when not applicable
+func (f Formatter) Delta(key string, cur, prev interface{}) string {
+	c, ok := number(cur)
+	if !ok {
+		return ""
+	}
+	p, ok := number(prev)
+	if !ok || c == p {
+		return ""
+	}
+
+	sign := "+"
+	d := c - p
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	format := statFormat(key)
+	if !f.Humanize || format == FormatPlain {
+		return sign + strconv.FormatFloat(d, 'f', -1, 64)
+	}
+	if format == FormatPercent {
+		return ""
+	}
+	return sign + formatNumber(format, d)
+}
+
+// rawValue writes the value the way the server does
+func rawValue(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	case time.Duration:
+		return strconv.FormatInt(int64(v/time.Second), 10)
+	}
+	return fmt.Sprint(v)
+}
+
+// number returns the numeric value, durations in seconds
+// Stats unknown to the walker package are kept as text and parsed here
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case time.Duration:
+		return v.Seconds(), true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func formatNumber(format ValueFormat, n float64) string {
+	switch format {
+	case FormatThousands:
+		return thousands(int64(n))
+	case FormatDuration:
+		return humanDuration(time.Duration(n * float64(time.Second)))
+	case FormatBytes:
+		return humanBytes(int64(n))
+	case FormatSI:
+		if n != math.Trunc(n) {
+			return strconv.FormatFloat(n, 'f', 2, 64)
+		}
+		return humanCount(int64(n))
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+// thousands groups the digits of the number by three, e.g. 8,734,521
+func thousands(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	sign := ""
+	if n < 0 {
+		sign, s = "-", s[1:]
+	}
+
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return sign + s
+}
+
+// humanCount abbreviates the number with SI suffixes, e.g. 1234 as 1.2k
+func humanCount(n int64) string {
+	return abbreviate(n, 1000, []string{"k", "M", "G", "T", "P", "E"})
+}
+
+// humanBytes writes the size with binary suffixes, e.g. 65535 as 64KiB
+func humanBytes(n int64) string {
+	if n > -1024 && n < 1024 {
+		return strconv.FormatInt(n, 10) + "B"
+	}
+	return abbreviate(n, 1024, []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"})
+}
+
+func abbreviate(n, unit int64, suffixes []string) string {
+	if n > -unit && n < unit {
+		return strconv.FormatInt(n, 10)
+	}
+
+	// the unit is picked on the rounded value, 999999 is 1M rather than 1000k
+	v := float64(n)
+	suffix := ""
+	for _, s := range suffixes {
+		if r := roundAbbreviated(v); r > -float64(unit) && r < float64(unit) {
+			break
+		}
+		v /= float64(unit)
+		suffix = s
+	}
+
+	v = roundAbbreviated(v)
+	if v >= 100 || v <= -100 {
+		return fmt.Sprintf("%.0f%s", v, suffix)
+	}
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", v), ".0") + suffix
+}
+
+// roundAbbreviated rounds the value as it is written, one decimal below 100
+func roundAbbreviated(v float64) float64 {
+	if v >= 100 || v <= -100 {
+		return math.Round(v)
+	}
+	return math.Round(v*10) / 10
+}
+
+// humanDuration writes the duration with its two most significant units, e.g. 3h12m
+func humanDuration(d time.Duration) string {
+	d = d.Truncate(time.Second)
+	day := 24 * time.Hour
+
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", d/time.Second)
+	case d < time.Hour:
+		return fmt.Sprintf("%dm%ds", d/time.Minute, d%time.Minute/time.Second)
+	case d < day:
+		return fmt.Sprintf("%dh%dm", d/time.Hour, d%time.Hour/time.Minute)
+	}
+	return fmt.Sprintf("%dd%dh", d/day, d%day/time.Hour)
+}
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestHumanCount(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1k"},
+		{1234, "1.2k"},
+		{99960, "100k"},
+		{999499, "999k"},
+		{999999, "1M"},
+		{1000000, "1M"},
+		{1250000, "1.3M"},
+		{-999999, "-1M"},
+	}
+	for _, tt := range tests {
+		if got := humanCount(tt.in); got != tt.want {
+			t.Errorf("humanCount(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHumanBytes(t *testing.T) {
+	tests := []struct {
+		in   int64
+		want string
+	}{
+		{1023, "1023B"},
+		{1024, "1KiB"},
+		{65535, "64KiB"},
+		{1048575, "1MiB"},
+		{1048576, "1MiB"},
+		{1536 * 1024, "1.5MiB"},
+	}
+	for _, tt := range tests {
+		if got := humanBytes(tt.in); got != tt.want {
+			t.Errorf("humanBytes(%d) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
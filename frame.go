@@ -1,12 +1,17 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/kr/beanstalk"
+	"github.com/kadekcipta/beanwalker/decode"
+	"github.com/kadekcipta/beanwalker/walker"
 	"github.com/mattn/go-runewidth"
 	"github.com/nsf/termbox-go"
 )
@@ -21,52 +26,98 @@ type controlCmd struct {
 
 const (
 	titleLine            = "Beanwalker - A simple beanstalkd status monitor and control "
-	connectionInfo       = "%s:%d"
 	beanstalkVersionInfo = "(beanstalkd v%s)"
-	deletionMessage      = "%s: %d %s jobs %s"
+	tubeDetailTitle      = "[ Tube: %s ]"
+	jobInspectorTitle    = "[ Jobs: %s ]"
+	dumpPrompt           = "Dump %s to file [states]: "
+	dumpLabel            = "Dump %s"
+	dumpedStatus         = "%s to %s, job ids %d-%d scanned"
+	connectionsTitle     = "[ Connections: %d producers, %d workers, %d waiting ]"
+	noWatchersStatus     = "NO WATCHERS"
+	drainingBadge        = " DRAINING "
 
 	infoColor = termbox.ColorDefault
 )
 
 var hostInfo string
 
-func strToInt(s string) int {
-	i, err := strconv.Atoi(s)
-	if err != nil {
-		panic(err)
+// sysStatsGroups lists the categories of system stats in key/value layout
+var sysStatsGroups = []string{"jobs", "commands", "connections", "binlog", "resources"}
+
+// sysStatsGroup returns the category of the system stat
+func sysStatsGroup(name string) string {
+	switch {
+	case strings.HasPrefix(name, "current-jobs-"), name == "total-jobs", name == "job-timeouts", name == "max-job-size", name == "current-tubes":
+		return "jobs"
+	case strings.HasPrefix(name, "cmd-"):
+		return "commands"
+	case strings.HasPrefix(name, "current-"), name == "total-connections":
+		return "connections"
+	case strings.HasPrefix(name, "binlog-"):
+		return "binlog"
 	}
-
-	return i
+	return "resources"
 }
 
 type mainFrame struct {
-	c              *beanstalk.Conn
+	client         *walker.Client
 	statEvt        chan struct{}
 	tubesStatsGrid *ScrollableGrid
 	sysStatsGrid   *ScrollableGrid
+	connStatsGrid  *ScrollableGrid
+	detailPanel    *KeyValuePanel
+	detailTube     string
+	inspectPanel   *KeyValuePanel
+	inspectReturn  Control
+	searchGrid     *ScrollableGrid
+	searchTube     string
+	searchRows     [][]string
+	prompt         *InputLine
+	config         *Config
+	sample         *walker.Sample
+	recorder       *json.Encoder
+	replay         *timeline
+	history        *timeline
+	hub            *sampleHub
+	replayCommands []controlCmd
+	sysStats       *walker.ServerStats
+	tubeStats      map[string]*walker.TubeStats
+	tubeNames      []string
+	prevTubeStats  map[string]*walker.TubeStats
+	statsLock      sync.RWMutex
+	formatter      Formatter
+	bp             BufferProxy
 	controls       []Control
 	focusIndex     int
 	bsVersion      string
 	debugText      string
 	commands       []controlCmd
+	searchCommands []controlCmd
 	done           chan struct{}
-	host           string
-	port           int
+	tasks          chan func()
+	scan           *jobScan
+	addr           string
+	dialer         *walker.Dialer
 }
 
 func (m *mainFrame) Clear(fg termbox.Attribute, bg termbox.Attribute) {
-	termbox.Clear(fg, bg)
+	m.bp.Clear(fg, bg)
 }
 
 func (m *mainFrame) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
-	termbox.SetCell(x, y, ch, fg, bg)
+	m.bp.SetCell(x, y, ch, fg, bg)
 }
 
 func (m *mainFrame) WriteText(x, y int, fg, bg termbox.Attribute, s string) {
-	for _, c := range s {
-		termbox.SetCell(x, y, c, fg, bg)
-		x++
-	}
+	m.bp.WriteText(x, y, fg, bg, s)
+}
+
+func (m *mainFrame) Size() (int, int) {
+	return m.bp.Size()
+}
+
+func (m *mainFrame) Flush() error {
+	return m.bp.Flush()
 }
 
 func (m *mainFrame) showStatus(s string) {
@@ -82,79 +133,29 @@ func (m *mainFrame) quit() error {
 func (m *mainFrame) kickJobs() error {
 	tubeName := m.currentTubeName()
 	if tubeName != "" {
-		t := &beanstalk.Tube{m.c, tubeName}
-		stats, err := t.Stats()
+		r, err := m.client.Kick(tubeName, 0)
 		if err != nil {
 			return err
 		}
-		n := strToInt(stats["current-jobs-buried"])
-		kicked, err := t.Kick(n)
-		if err != nil {
-			return err
-		}
-		m.showStatus(fmt.Sprintf(deletionMessage, m.currentTubeName(), kicked, "on hold", "kicked"))
+		m.showStatus(r.String())
 	}
 
 	return nil
 }
 
 func (m *mainFrame) buryJobs() error {
-	tubeSet := beanstalk.NewTubeSet(m.c, m.currentTubeName())
-	n := 0
-	var lastError error
-
-	for {
-		id, _, err := tubeSet.Reserve(time.Second)
-		if err != nil {
-			lastError = lastError
-			break
-		}
-		stats, err := m.c.StatsJob(id)
-		if err != nil {
-			lastError = err
-			break
-		}
-		pri := strToInt(stats["pri"])
-		if err := m.c.Bury(id, uint32(pri)); err != nil {
-			lastError = err
-			break
-		}
-		n++
-	}
-	m.showStatus(fmt.Sprintf("%s: %d jobs buried", m.currentTubeName(), n))
+	r, err := m.client.Bury(m.currentTubeName())
+	m.showStatus(r.String())
 
-	return lastError
+	return err
 }
 
-// deleteJobs deletes the jobs with specified state
-func (m *mainFrame) deleteJobs(c *beanstalk.Conn, tubeName, state string) (int, error) {
-	var id uint64
-	var err error
+// deleteJobs deletes the jobs with specified state on the current tube
+func (m *mainFrame) deleteJobs(state walker.State) error {
+	r, err := m.client.Delete(m.currentTubeName(), state)
+	m.showStatus(r.String())
 
-	t := &beanstalk.Tube{c, tubeName}
-	n := 0
-
-	for {
-		switch state {
-		case "ready":
-			id, _, err = t.PeekReady()
-		case "buried":
-			id, _, err = t.PeekBuried()
-		case "delayed":
-			id, _, err = t.PeekDelayed()
-		}
-
-		if err != nil {
-			return n, err
-		}
-
-		if err := c.Delete(id); err != nil {
-			return n, err
-		}
-		n++
-	}
-
-	return n, nil
+	return err
 }
 
 func (m *mainFrame) currentTubeName() string {
@@ -168,30 +169,110 @@ func (m *mainFrame) currentTubeName() string {
 }
 
 func (m *mainFrame) deleteReadyJobs() error {
-	n, err := m.deleteJobs(m.c, m.currentTubeName(), "ready")
-	m.showStatus(fmt.Sprintf(deletionMessage, m.currentTubeName(), n, "ready", "deleted"))
-	return err
+	return m.deleteJobs(walker.StateReady)
 }
 
 func (m *mainFrame) deleteBuriedJobs() error {
-	n, err := m.deleteJobs(m.c, m.currentTubeName(), "buried")
-	m.showStatus(fmt.Sprintf(deletionMessage, m.currentTubeName(), n, "buried", "deleted"))
-	return err
+	return m.deleteJobs(walker.StateBuried)
 }
 
 func (m *mainFrame) deleteDelayedJobs() error {
-	n, err := m.deleteJobs(m.c, m.currentTubeName(), "delayed")
-	m.showStatus(fmt.Sprintf(deletionMessage, m.currentTubeName(), n, "delayed", "deleted"))
-	return err
+	return m.deleteJobs(walker.StateDelayed)
+}
+
+// promptDump asks for the dump file and the optional comma separated states, every peekable state by default
+func (m *mainFrame) promptDump() error {
+	tubeName := m.currentTubeName()
+	if tubeName == "" {
+		return nil
+	}
+	m.ask(fmt.Sprintf(dumpPrompt, tubeName), func(text string) error {
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			return nil
+		}
+		// the standard output is the terminal drawn by the user interface
+		if fields[0] == "-" {
+			return fmt.Errorf("dump: a file is required")
+		}
+		list := "ready,delayed,buried"
+		if len(fields) > 1 {
+			list = fields[1]
+		}
+		states, err := parseStates(list)
+		if err != nil {
+			return err
+		}
+		ids, err := m.client.LastIDs(scanIDs)
+		if err != nil {
+			return err
+		}
+
+		var r *walker.Result
+		dump := func(c *walker.Client) (err error) {
+			r, err = writeDump(c, fields[0], tubeName, states, ids)
+			return err
+		}
+		return m.startScan(fmt.Sprintf(dumpLabel, tubeName), ids, dump, func() error {
+			m.showStatus(fmt.Sprintf(dumpedStatus, r, fields[0], ids.From, ids.To))
+			return nil
+		})
+	})
+	return nil
+}
+
+// draining reports whether the server is in drain mode as of the last poll
+func (m *mainFrame) draining() bool {
+	m.statsLock.RLock()
+	defer m.statsLock.RUnlock()
+
+	return m.sysStats != nil && m.sysStats.Draining
+}
+
+func (m *mainFrame) toggleSysStatsLayout() error {
+	m.sysStatsGrid.ToggleLayout()
+	m.refresh()
+	return nil
+}
+
+// toggleHumanize switches between raw and humanized values and reformats the last polled stats
+func (m *mainFrame) toggleHumanize() error {
+	m.statsLock.Lock()
+	m.formatter.Humanize = !m.formatter.Humanize
+	m.statsLock.Unlock()
+
+	m.updateViews()
+	m.refresh()
+
+	return nil
+}
+
+// activeCommands returns the commands of the view shown in the lower region along with the view
+// Non global commands only apply while the view is focused
+func (m *mainFrame) activeCommands() ([]controlCmd, Control) {
+	if m.replay != nil {
+		return m.replayCommands, m.tubesStatsGrid
+	}
+	if m.searchGrid.Visible() {
+		return m.searchCommands, m.searchGrid
+	}
+	return m.commands, m.tubesStatsGrid
 }
 
 func (m *mainFrame) execCommand(key termbox.Key) {
-	for _, c := range m.commands {
+	if key == termbox.KeyEsc && m.cancelScan() {
+		return
+	}
+
+	commands, view := m.activeCommands()
+	for _, c := range commands {
 		if c.key == key && c.action != nil {
-			if !c.global && !m.tubesStatsGrid.Focused() {
+			if !c.global && !view.Focused() {
 				continue
 			}
-			c.action()
+			if err := c.action(); err != nil {
+				m.showStatus(err.Error())
+			}
 		}
 	}
 }
@@ -206,72 +287,304 @@ func (m *mainFrame) initCommands(x, y int) {
 		{termbox.KeyF5, " F5", "Del-Ready", false, m.deleteReadyJobs},
 		{termbox.KeyF6, " F6", "Del-Buried", false, m.deleteBuriedJobs},
 		{termbox.KeyF7, " F7", "Del-Delayed", false, m.deleteDelayedJobs},
+		{termbox.KeyEnter, "ENT", "Detail", false, m.showTubeDetail},
+		{termbox.KeyF2, " F2", "Sys-Layout", true, m.toggleSysStatsLayout},
+		{termbox.KeyF8, " F8", "Connections", true, m.toggleConnections},
+		{termbox.KeyF9, " F9", "Humanize", true, m.toggleHumanize},
+		{termbox.KeyF10, "F10", "Inspect", false, m.showJobInspector},
+		{termbox.KeyCtrlF, " ^f", "Search", false, m.promptSearch},
+		{termbox.KeyCtrlB, " ^b", "Bulk", false, m.promptBulk},
+		{termbox.KeyCtrlD, " ^d", "Dump", false, m.promptDump},
+		{termbox.KeyCtrlZ, " ^z", "Freeze", true, m.toggleFreeze},
+		{termbox.KeyPgup, "PgU", "Back", true, m.previousSample},
+		{termbox.KeyPgdn, "PgD", "Forward", true, m.nextSample},
 	}
+	m.searchCommands = []controlCmd{
+		{termbox.KeyCtrlQ, " ^q", "Quit", true, m.quit},
+		{termbox.KeyTab, "TAB", "Navigate", true, m.navigateFocus},
+		{termbox.Key(0), "\u2194 \u2195", "Scroll", true, nil},
+		{termbox.KeyEnter, "ENT", "Inspect", false, m.inspectSearchResult},
+		{termbox.KeyDelete, "DEL", "Delete", false, m.deleteSearchResult},
+		{termbox.KeyF5, " F5", "Del-All", false, m.deleteSearchResults},
+		{termbox.KeyEsc, "ESC", "Close", false, m.closeSearch},
+	}
+	m.replayCommands = []controlCmd{
+		{termbox.KeyCtrlQ, " ^q", "Quit", true, m.quit},
+		{termbox.KeyTab, "TAB", "Navigate", true, m.navigateFocus},
+		{termbox.Key(0), "\u2194 \u2195", "Scroll", true, nil},
+		{termbox.KeyEnter, "ENT", "Detail", false, m.showTubeDetail},
+		{termbox.KeyF2, " F2", "Sys-Layout", true, m.toggleSysStatsLayout},
+		{termbox.KeyF8, " F8", "Connections", true, m.toggleConnections},
+		{termbox.KeyF9, " F9", "Humanize", true, m.toggleHumanize},
+		{termbox.KeyCtrlP, " ^p", "Play/Pause", true, m.togglePlay},
+		{termbox.KeyCtrlS, " ^s", "Speed", true, m.cycleSpeed},
+		{termbox.KeyPgup, "PgU", "Previous", true, m.previousSample},
+		{termbox.KeyPgdn, "PgD", "Next", true, m.nextSample},
+		{termbox.KeyHome, "Hom", "First", true, m.firstSample},
+		{termbox.KeyEnd, "End", "Last", true, m.lastSample},
+		{termbox.KeyCtrlG, " ^g", "Seek", true, m.promptSeek},
+	}
+	commands, _ := m.activeCommands()
 
 	longest := 0
-	for _, c := range m.commands {
+	for _, c := range commands {
 		l := runewidth.StringWidth(c.shortcut+c.description) + 1
 		if l > longest {
 			longest = l
 		}
 	}
 
+	w, _ := m.Size()
 	dx := x
 	dy := y
-	for i, c := range m.commands {
+	for _, c := range commands {
+		// wrap to the next line when the command doesn't fit
+		if dx > x && dx+longest > w {
+			dy++
+			dx = x
+		}
 		m.WriteText(dx, dy, termbox.ColorRed, BGColor, c.shortcut)
 		dx += runewidth.StringWidth(c.shortcut) + 1
 		m.WriteText(dx, dy, FGColor, BGColor, c.description)
 		dx += longest - 2
+	}
+}
 
-		if i == 3 {
-			dy++
-			dx = x
+// sysStatsColumns are the columns of the system stats grid, the top command prints them too
+var sysStatsColumns = []GridColumn{
+	{"hostname", AlignLeft, 20},
+	{"current-jobs-urgent", AlignRight, 20},
+	{"current-jobs-ready", AlignRight, 23},
+	{"current-jobs-reserved", AlignRight, 25},
+	{"current-jobs-delayed", AlignRight, 21},
+	{"current-jobs-buried", AlignRight, 21},
+	{"cmd-put", AlignRight, 9},
+	{"cmd-peek", AlignRight, 10},
+	{"cmd-peek-ready", AlignRight, 16},
+	{"cmd-peek-delayed", AlignRight, 18},
+	{"cmd-peek-buried", AlignRight, 17},
+	{"cmd-reserve", AlignRight, 13},
+	{"cmd-use", AlignRight, 9},
+	{"cmd-watch", AlignRight, 11},
+	{"cmd-ignore", AlignRight, 12},
+	{"cmd-delete", AlignRight, 12},
+	{"cmd-release", AlignRight, 13},
+	{"cmd-bury", AlignRight, 10},
+	{"cmd-kick", AlignRight, 10},
+	{"cmd-stats-job", AlignRight, 15},
+	{"cmd-list-tube-used", AlignRight, 20},
+	{"cmd-list-tubes-watched", AlignRight, 24},
+	{"cmd-pause-tube", AlignRight, 16},
+	{"job-timeouts", AlignRight, 14},
+	{"total-jobs", AlignRight, 11},
+	{"max-job-size", AlignRight, 13},
+	{"current-tubes", AlignRight, 14},
+	{"current-connections", AlignRight, 21},
+	{"current-producers", AlignRight, 19},
+	{"current-workers", AlignRight, 17},
+	{"current-waiting", AlignRight, 17},
+	{"total-connections", AlignRight, 19},
+	{"pid", AlignRight, 10},
+	{"version", AlignRight, 10},
+	{"rusage-utime", AlignRight, 14},
+	{"rusage-stime", AlignRight, 14},
+	{"uptime", AlignRight, 10},
+	{"binlog-oldest-index", AlignRight, 21},
+	{"binlog-current-index", AlignRight, 22},
+	{"binlog-max-size", AlignRight, 17},
+	{"binlog-records-written", AlignRight, 24},
+	{"binlog-records-migrated", AlignRight, 25},
+	{"id", AlignRight, 20},
+}
+
+// tubeStatsColumns are the columns of the tubes stats grid, the top command prints them too
+var tubeStatsColumns = []GridColumn{
+	{"name", AlignLeft, 25},
+	{"current-jobs-urgent", AlignRight, 21},
+	{"current-jobs-ready", AlignRight, 21},
+	{"current-jobs-reserved", AlignRight, 25},
+	{"current-jobs-delayed", AlignRight, 21},
+	{"current-jobs-buried", AlignRight, 21},
+	{"total-jobs", AlignRight, 12},
+	{"current-using", AlignRight, 15},
+	{"current-waiting", AlignRight, 17},
+	{"current-watching", AlignRight, 18},
+	{"pause", AlignRight, 7},
+	{"cmd-delete", AlignRight, 11},
+	{"cmd-pause-tube", AlignRight, 16},
+	{"pause-time-left", AlignRight, 17},
+}
+
+// copyColumns returns a copy of the columns, grids append the discovered ones to their own
+func copyColumns(columns []GridColumn) []GridColumn {
+	return append([]GridColumn{}, columns...)
+}
+
+// discoverColumns appends the stats unknown to the grid as extra columns, so newer server versions are kept up with
+func discoverColumns(grid *ScrollableGrid, stats map[string]string) {
+	if cols := unknownColumns(grid.Columns, stats); len(cols) > 0 {
+		grid.AppendColumns(cols...)
+	}
+}
+
+// unknownColumns returns the stats missing from the columns as extra columns, sorted by name
+func unknownColumns(columns []GridColumn, stats map[string]string) []GridColumn {
+	known := map[string]bool{}
+	for _, col := range columns {
+		known[col.Name] = true
+	}
+
+	names := []string{}
+	for k := range stats {
+		if !known[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	cols := []GridColumn{}
+	for _, name := range names {
+		cols = append(cols, GridColumn{name, AlignRight, len(name) + 2})
+	}
+	return cols
+}
+
+// applySample shows the polled or replayed sample, deltas are computed against the previous one
+func (m *mainFrame) applySample(sample, prev *walker.Sample) {
+	discoverColumns(m.sysStatsGrid, sample.System.Raw)
+
+	tubeNames := []string{}
+	tubeStats := map[string]*walker.TubeStats{}
+	for _, stats := range sample.Tubes {
+		tubeNames = append(tubeNames, stats.Name)
+		tubeStats[stats.Name] = stats
+		discoverColumns(m.tubesStatsGrid, stats.Raw)
+	}
+	prevTubeStats := map[string]*walker.TubeStats{}
+	if prev != nil {
+		for _, stats := range prev.Tubes {
+			prevTubeStats[stats.Name] = stats
 		}
 	}
+
+	m.statsLock.Lock()
+	m.sample = sample
+	m.sysStats = sample.System
+	m.prevTubeStats = prevTubeStats
+	m.tubeStats = tubeStats
+	m.tubeNames = tubeNames
+	m.statsLock.Unlock()
+
+	m.updateViews()
 }
 
-func (m *mainFrame) getSystemStats() [][]string {
-	// list tubes
-	stats, err := m.c.Stats()
-	if err != nil {
-		return nil
+// updateViews formats the stats of the current sample into the grids and the tube detail
+func (m *mainFrame) updateViews() {
+	m.sysStatsGrid.UpdateData(m.sysStatsRows())
+	m.tubesStatsGrid.UpdateData(m.tubeStatsRows())
+	if m.detailPanel.Visible() {
+		m.detailPanel.UpdateData(m.getTubeDetail(m.detailTube))
 	}
+	m.connStatsGrid.UpdateData(m.getConnectionStats())
+}
 
-	data := [][]string{}
-	row := []string{}
-	// get headers as reference
-	for _, col := range m.sysStatsGrid.Columns {
-		value, _ := stats[col.Name]
-		row = append(row, value)
+// sysStatsRows formats the last polled system stats
+func (m *mainFrame) sysStatsRows() [][]string {
+	m.statsLock.RLock()
+	defer m.statsLock.RUnlock()
+
+	if m.sysStats == nil {
+		return nil
 	}
+	return [][]string{m.formatter.Row(m.sysStatsGrid.Columns, m.sysStats)}
+}
 
-	data = append(data, row)
+// tubeStatsRows formats the last polled tube stats in the server order
+func (m *mainFrame) tubeStatsRows() [][]string {
+	m.statsLock.RLock()
+	defer m.statsLock.RUnlock()
 
+	data := [][]string{}
+	for _, name := range m.tubeNames {
+		data = append(data, m.formatter.Row(m.tubesStatsGrid.Columns, m.tubeStats[name]))
+	}
 	return data
 }
 
-func (m *mainFrame) getTubeStats() [][]string {
-	// list tubes
-	tubes, err := m.c.ListTubes()
+// headJob returns the short description of the next job of the state
+func (m *mainFrame) headJob(tubeName string, state walker.State) string {
+	// jobs aren't recorded
+	if m.client == nil {
+		return "-"
+	}
+	job, err := m.client.Peek(tubeName, state)
 	if err != nil {
+		return "-"
+	}
+	preview := strings.Join(strings.Fields(string(job.Body)), " ")
+	return fmt.Sprintf("#%d %s", job.ID, preview)
+}
+
+// getTubeDetail returns every stat of the tube along with the deltas since the last poll and the head jobs
+// The head jobs are peeked with the client, like every caller it runs on the event loop
+func (m *mainFrame) getTubeDetail(tubeName string) []KeyValueRow {
+	m.statsLock.RLock()
+	stats, ok := m.tubeStats[tubeName]
+	prevStats := m.prevTubeStats[tubeName]
+	f := m.formatter
+	m.statsLock.RUnlock()
+	if !ok {
 		return nil
 	}
 
-	data := [][]string{}
+	prev := map[string]interface{}{}
+	if prevStats != nil {
+		prev = walker.FieldMap(prevStats)
+	}
 
-	for _, tubeName := range tubes {
-		tube := &beanstalk.Tube{m.c, tubeName}
-		stats, err := tube.Stats()
-		if err != nil {
-			return nil
+	// known stats come first, the rest are sorted by name
+	values := walker.FieldMap(stats)
+	rows := []KeyValueRow{{Key: "stats"}}
+	for _, field := range walker.Fields(stats) {
+		if _, ok := stats.Raw[field.Key]; !ok {
+			continue
 		}
-		row := []string{}
+		rows = append(rows, KeyValueRow{field.Key, f.Format(field.Key, values), f.Delta(field.Key, field.Value, prev[field.Key])})
+	}
+
+	rows = append(rows, KeyValueRow{Key: "head jobs"})
+	for _, state := range []walker.State{walker.StateReady, walker.StateDelayed, walker.StateBuried} {
+		rows = append(rows, KeyValueRow{Key: string(state), Value: m.headJob(tubeName, state)})
+	}
+
+	return rows
+}
+
+// getConnectionStats returns producers, watchers and waiting workers per tube
+// Tubes having ready jobs without any watcher are flagged
+func (m *mainFrame) getConnectionStats() [][]string {
+	m.statsLock.RLock()
+	defer m.statsLock.RUnlock()
+
+	if m.sysStats != nil {
+		m.connStatsGrid.SetTitle(fmt.Sprintf(connectionsTitle,
+			m.sysStats.CurrentProducers, m.sysStats.CurrentWorkers, m.sysStats.CurrentWaiting))
+	}
 
-		// get headers as reference
-		for _, col := range m.tubesStatsGrid.Columns {
-			value, _ := stats[col.Name]
-			row = append(row, value)
+	names := []string{}
+	for name := range m.tubeStats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data := [][]string{}
+	for _, name := range names {
+		stats := m.tubeStats[name]
+		row := m.formatter.Row(m.connStatsGrid.Columns, stats)
+		for i, col := range m.connStatsGrid.Columns {
+			if col.Name == "status" && stats.CurrentJobsReady > 0 && stats.CurrentWatching == 0 {
+				row[i] = noWatchersStatus
+			}
 		}
 		data = append(data, row)
 	}
@@ -279,58 +592,234 @@ func (m *mainFrame) getTubeStats() [][]string {
 	return data
 }
 
-func (m *mainFrame) pollStats(interval int) {
-	m.statEvt = make(chan struct{})
+// showView shows the control in the lower region in place of the other views and focuses it
+func (m *mainFrame) showView(view Control) {
+	for _, c := range []Control{m.tubesStatsGrid, m.detailPanel, m.inspectPanel, m.connStatsGrid, m.searchGrid} {
+		c.SetVisible(c == view)
+	}
+	m.setFocus(view)
+}
+
+func (m *mainFrame) toggleConnections() error {
+	if m.connStatsGrid.Visible() {
+		m.showView(m.tubesStatsGrid)
+	} else {
+		m.detailTube = ""
+		m.showView(m.connStatsGrid)
+	}
+	m.refresh()
+
+	return nil
+}
+
+func (m *mainFrame) showTubeDetail() error {
+	tubeName := m.currentTubeName()
+	if tubeName == "" {
+		return nil
+	}
+	m.detailTube = tubeName
+	m.detailPanel.Title = fmt.Sprintf(tubeDetailTitle, tubeName)
+	m.detailPanel.UpdateData(m.getTubeDetail(tubeName))
+	m.showView(m.detailPanel)
+	m.refresh()
+
+	return nil
+}
 
-	collectStats := func() {
-		sysData := m.getSystemStats()
-		if sysData != nil {
-			m.sysStatsGrid.UpdateData(sysData)
+func (m *mainFrame) closeTubeDetail() {
+	m.detailTube = ""
+	m.showView(m.tubesStatsGrid)
+}
+
+// inspectJob returns the stats of the job followed by its body, decoded by the named decoder or the sniffed one
+func (m *mainFrame) inspectJob(job *walker.Job, decoder string) []KeyValueRow {
+	m.statsLock.RLock()
+	f := m.formatter
+	m.statsLock.RUnlock()
+
+	rows := []KeyValueRow{}
+	stats, err := m.client.JobStats(job.ID)
+	if err != nil {
+		rows = append(rows, KeyValueRow{"error", err.Error(), ""})
+	} else {
+		values := walker.FieldMap(stats)
+		for _, field := range walker.Fields(stats) {
+			rows = append(rows, KeyValueRow{field.Key, f.Format(field.Key, values), ""})
 		}
+	}
+
+	decoded, err := decode.Default.Decode(job.Body, decoder)
+	if err != nil {
+		// show what could be decoded as hex dump
+		rows = append(rows, KeyValueRow{"error", err.Error(), ""})
+		decoded.Body, _ = decode.Hex.Decode(decoded.Body)
+		decoded.Chain = append(decoded.Chain, decode.Hex.Name)
+	}
 
-		tubeData := m.getTubeStats()
-		if tubeData != nil {
-			m.tubesStatsGrid.UpdateData(tubeData)
+	rows = append(rows, KeyValueRow{Key: fmt.Sprintf("body (%s)", strings.Join(decoded.Chain, " > "))})
+	body := strings.NewReplacer("\r", "", "\t", "    ").Replace(string(decoded.Body))
+	for i, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+		// empty value would be drawn as heading
+		if line == "" {
+			line = " "
 		}
+		rows = append(rows, KeyValueRow{strconv.Itoa(i + 1), line, ""})
 	}
 
-	collectStats()
+	return rows
+}
 
-	go func() {
-		defer close(m.statEvt)
+// showJobInspector shows the next ready, delayed and buried jobs of the current tube with decoded bodies
+func (m *mainFrame) showJobInspector() error {
+	tubeName := m.currentTubeName()
+	if tubeName == "" {
+		return nil
+	}
+
+	rows := []KeyValueRow{}
+	for _, state := range []walker.State{walker.StateReady, walker.StateDelayed, walker.StateBuried} {
+		job, err := m.client.Peek(tubeName, state)
+		if err != nil {
+			if walker.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		rows = append(rows, KeyValueRow{Key: fmt.Sprintf("%s job #%d", state, job.ID)})
+		rows = append(rows, m.inspectJob(job, m.config.decoder(tubeName))...)
+	}
+	if len(rows) == 0 {
+		rows = append(rows, KeyValueRow{Key: "no ready, delayed or buried jobs"})
+	}
+
+	m.openInspector(fmt.Sprintf(jobInspectorTitle, tubeName), rows, m.tubesStatsGrid)
+
+	return nil
+}
+
+// openInspector shows the rows in the job inspector, closing it goes back to the view
+func (m *mainFrame) openInspector(title string, rows []KeyValueRow, from Control) {
+	m.inspectPanel.Title = title
+	m.inspectPanel.UpdateData(rows)
+	m.inspectReturn = from
+	m.showView(m.inspectPanel)
+	m.refresh()
+}
+
+func (m *mainFrame) closeJobInspector() {
+	m.showView(m.inspectReturn)
+}
 
+// ask shows the prompt on the status line, the focus goes back to the focused control once answered
+func (m *mainFrame) ask(label string, submit func(string) error) {
+	var prev Control
+	for _, c := range m.controls {
+		if c.Focused() {
+			prev = c
+		}
+	}
+	done := func() {
+		m.prompt.SetVisible(false)
+		if prev != nil {
+			m.setFocus(prev)
+		}
+	}
+
+	m.prompt.Ask(label, func(text string) {
+		done()
+		if err := submit(text); err != nil {
+			m.showStatus(err.Error())
+		}
+	}, done)
+	m.prompt.SetVisible(true)
+	m.setFocus(m.prompt)
+	m.refresh()
+}
+
+func (m *mainFrame) pollStats(interval int) {
+	m.statEvt = make(chan struct{})
+	if m.replay != nil {
+		m.playReplay()
+		return
+	}
+	m.history = &timeline{live: true}
+	m.collectStats()
+
+	// the samples are polled on the event loop, which the client and the views belong to
+	go func() {
 		for {
 			<-time.After(time.Duration(interval) * time.Second)
-			collectStats()
-			m.statEvt <- struct{}{}
+			m.post(m.collectStats)
 		}
 	}()
 }
 
+// collectStats polls a sample into the history, it is recorded and published as well
+// It runs on the event loop
+func (m *mainFrame) collectStats() {
+	sample, err := m.client.Sample()
+	if err != nil {
+		m.debugText = err.Error()
+		return
+	}
+	if m.recorder != nil {
+		if err := m.recorder.Encode(sample); err != nil {
+			m.debugText = err.Error()
+		}
+	}
+
+	if m.hub != nil {
+		m.hub.publish(sample)
+	}
+
+	// frozen histories keep showing the chosen sample
+	m.history.add(sample, historyWindow)
+	if !m.history.isFrozen() {
+		m.showSample()
+	}
+}
+
 func (m *mainFrame) redraw() {
 	m.Clear(termbox.ColorDefault, BGColor)
-	w, h := termbox.Size()
-	m.sysStatsGrid.Resize(BufferRegion{1, 2, w - 3, 5})
-	m.tubesStatsGrid.Resize(BufferRegion{1, 8, w - 3, h - 12})
+	w, h := m.Size()
+	sysHeight := 5
+	if m.sysStatsGrid.Layout == LayoutKeyValue {
+		sysHeight = m.sysStatsGrid.PreferredHeight(w - 3)
+		if sysHeight > h/2 {
+			sysHeight = h / 2
+		}
+	}
+	m.sysStatsGrid.Resize(BufferRegion{1, 2, w - 3, sysHeight})
+	m.tubesStatsGrid.Resize(BufferRegion{1, sysHeight + 3, w - 3, h - sysHeight - 7})
+	m.detailPanel.Resize(BufferRegion{1, sysHeight + 3, w - 3, h - sysHeight - 7})
+	m.inspectPanel.Resize(BufferRegion{1, sysHeight + 3, w - 3, h - sysHeight - 7})
+	m.searchGrid.Resize(BufferRegion{1, sysHeight + 3, w - 3, h - sysHeight - 7})
+	m.connStatsGrid.Resize(BufferRegion{1, sysHeight + 3, w - 3, h - sysHeight - 7})
 
 	m.WriteText(1, 1, infoColor, termbox.ColorDefault, titleLine)
 	beanstalkInfo := hostInfo + " " + fmt.Sprintf(beanstalkVersionInfo, m.bsVersion)
-	m.WriteText(w-runewidth.StringWidth(beanstalkInfo)-1, 1, termbox.ColorRed|termbox.AttrBold, BGColor, beanstalkInfo)
+	if t := m.timeline(); t != nil {
+		if info := t.info(); info != "" {
+			beanstalkInfo = info + " " + beanstalkInfo
+		}
+	}
+	infoX := w - runewidth.StringWidth(beanstalkInfo) - 1
+	m.WriteText(infoX, 1, termbox.ColorRed|termbox.AttrBold, BGColor, beanstalkInfo)
+	if m.draining() {
+		m.WriteText(infoX-runewidth.StringWidth(drainingBadge)-1, 1, termbox.ColorWhite|termbox.AttrBold, termbox.ColorRed, drainingBadge)
+	}
 	m.initCommands(2, h-4)
 	m.WriteText(1, h-1, termbox.ColorYellow, BGColor, m.debugText)
+	m.prompt.Resize(BufferRegion{1, h - 1, w - 2, 1})
 }
 
 func (m *mainFrame) refresh() {
 	m.redraw()
-	termbox.Flush()
+	m.Flush()
 }
 
-func (m *mainFrame) createConnection() (*beanstalk.Conn, error) {
-	c, err := beanstalk.Dial("tcp", fmt.Sprintf("%s:%d", m.host, m.port))
-	if err != nil {
-		return nil, err
-	}
-	return c, nil
+func (m *mainFrame) createConnection() (*walker.Client, error) {
+	return m.dialer.Dial(m.addr)
 }
 
 func (m *mainFrame) connect() error {
@@ -338,24 +827,24 @@ func (m *mainFrame) connect() error {
 	if err != nil {
 		return err
 	}
-	m.c = c
+	m.client = c
 	// get server version
-	stats, err := c.Stats()
+	stats, err := c.ServerStats()
 	if err != nil {
 		return err
 	}
+	m.bsVersion = stats.Version
 
-	version, ok := stats["version"]
-	if ok {
-		m.bsVersion = version
-	}
+	m.statsLock.Lock()
+	m.sysStats = stats
+	m.statsLock.Unlock()
 
 	return nil
 }
 
 func (m *mainFrame) disconnect() {
-	if m.c != nil {
-		m.c.Close()
+	if m.client != nil {
+		m.client.Close()
 	}
 }
 
@@ -369,20 +858,39 @@ func (m *mainFrame) dispatchEvent(ev termbox.Event) bool {
 	return false
 }
 
-func (m *mainFrame) navigateFocus() error {
-	// list of visible controls
+func (m *mainFrame) visibleControls() []Control {
 	visibles := []Control{}
 	for _, c := range m.controls {
-		c.SetFocus(false)
 		if c.Visible() {
 			visibles = append(visibles, c)
 		}
 	}
+	return visibles
+}
+
+// setFocus moves the focus to the control and keeps the focus index in sync
+func (m *mainFrame) setFocus(control Control) {
+	for _, c := range m.controls {
+		c.SetFocus(false)
+	}
+	for i, c := range m.visibleControls() {
+		if c == control {
+			m.focusIndex = i
+		}
+	}
+	control.SetFocus(true)
+}
+
+func (m *mainFrame) navigateFocus() error {
+	visibles := m.visibleControls()
+	if len(visibles) == 0 {
+		return nil
+	}
 	m.focusIndex++
 	if m.focusIndex > len(visibles)-1 {
 		m.focusIndex = 0
 	}
-	m.controls[m.focusIndex].SetFocus(true)
+	m.setFocus(visibles[m.focusIndex])
 	m.refresh()
 
 	return nil
@@ -436,75 +944,51 @@ func (m *mainFrame) startLoop(interval int) {
 
 		case <-m.statEvt:
 			m.refresh()
+
+		case fn := <-m.tasks:
+			fn()
+			m.refresh()
 		}
 	}
 }
 
-func (m *mainFrame) show(host string, port, pollInterval int) {
-	m.host = host
-	m.port = port
-	// try to connect, exit on failure
-	if err := m.connect(); err != nil {
-		fmt.Fprintln(os.Stderr, err.Error())
-		os.Exit(-1)
+func (m *mainFrame) show(addr string, pollInterval int) {
+	m.addr = addr
+	if m.replay != nil {
+		// recorded samples are shown instead of the server ones
+		first, _ := m.replay.current()
+		hostInfo = fmt.Sprintf(replayHostInfo, first.System.Hostname)
+		m.bsVersion = first.System.Version
+	} else {
+		// try to connect, exit on failure
+		if err := m.connect(); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(-1)
+		}
+		hostInfo = addr
 	}
 
-	hostInfo = fmt.Sprintf(connectionInfo, host, port)
 	m.done = make(chan struct{})
+	m.tasks = make(chan func())
+	if m.bp == nil {
+		m.bp = termboxProxy{}
+	}
 
+	m.initControls()
+	m.startLoop(pollInterval)
+}
+
+// initControls creates the grids and panels drawn to the buffer proxy
+func (m *mainFrame) initControls() {
 	if m.controls == nil {
 		m.focusIndex = 0
 		m.controls = []Control{}
 
 		// system stats
 		m.sysStatsGrid = &ScrollableGrid{
-			Title: "[ System Stats ]",
-			BP:    m,
-			Columns: []GridColumn{
-				{"hostname", AlignLeft, 20},
-				{"current-jobs-urgent", AlignRight, 20},
-				{"current-jobs-ready", AlignRight, 23},
-				{"current-jobs-reserved", AlignRight, 25},
-				{"current-jobs-delayed", AlignRight, 21},
-				{"current-jobs-buried", AlignRight, 21},
-				{"cmd-put", AlignRight, 9},
-				{"cmd-peek", AlignRight, 10},
-				{"cmd-peek-ready", AlignRight, 16},
-				{"cmd-peek-delayed", AlignRight, 18},
-				{"cmd-peek-buried", AlignRight, 17},
-				{"cmd-reserve", AlignRight, 13},
-				{"cmd-use", AlignRight, 9},
-				{"cmd-watch", AlignRight, 11},
-				{"cmd-ignore", AlignRight, 12},
-				{"cmd-delete", AlignRight, 12},
-				{"cmd-release", AlignRight, 13},
-				{"cmd-bury", AlignRight, 10},
-				{"cmd-kick", AlignRight, 10},
-				{"cmd-stats-job", AlignRight, 15},
-				{"cmd-list-tube-used", AlignRight, 20},
-				{"cmd-list-tubes-watched", AlignRight, 24},
-				{"cmd-pause-tube", AlignRight, 16},
-				{"job-timeouts", AlignRight, 14},
-				{"total-jobs", AlignRight, 11},
-				{"max-job-size", AlignRight, 13},
-				{"current-tubes", AlignRight, 14},
-				{"current-connections", AlignRight, 21},
-				{"current-producers", AlignRight, 19},
-				{"current-workers", AlignRight, 17},
-				{"current-waiting", AlignRight, 17},
-				{"total-connections", AlignRight, 19},
-				{"pid", AlignRight, 10},
-				{"version", AlignRight, 10},
-				{"rusage-utime", AlignRight, 14},
-				{"rusage-stime", AlignRight, 14},
-				{"uptime", AlignRight, 10},
-				{"binlog-oldest-index", AlignRight, 21},
-				{"binlog-current-index", AlignRight, 22},
-				{"binlog-max-size", AlignRight, 17},
-				{"binlog-records-written", AlignRight, 24},
-				{"binlog-records-migrated", AlignRight, 25},
-				{"id", AlignRight, 20},
-			},
+			Title:   "[ System Stats ]",
+			BP:      m,
+			Columns: copyColumns(sysStatsColumns),
 		}
 		m.sysStatsGrid.SetCustomDrawFunc(func(index int, col, value string) (termbox.Attribute, termbox.Attribute) {
 			if col == m.sysStatsGrid.Columns[0].Name {
@@ -512,6 +996,7 @@ func (m *mainFrame) show(host string, port, pollInterval int) {
 			}
 			return FGColor, BGColor
 		})
+		m.sysStatsGrid.SetGroupFunc(sysStatsGroups, sysStatsGroup)
 		m.sysStatsGrid.SetVisible(true)
 		m.sysStatsGrid.reset()
 		m.controls = append(m.controls, m.sysStatsGrid)
@@ -520,30 +1005,67 @@ func (m *mainFrame) show(host string, port, pollInterval int) {
 			VScroller: true,
 			Title:     "[ Tubes Stats ]",
 			BP:        m,
+			Columns:   copyColumns(tubeStatsColumns),
+		}
+		m.tubesStatsGrid.SetVisible(true)
+		m.tubesStatsGrid.reset()
+		m.controls = append(m.controls, m.tubesStatsGrid)
+
+		// tube detail, shown in place of tubes stats
+		m.detailPanel = &KeyValuePanel{BP: m}
+		m.detailPanel.SetCloseFunc(m.closeTubeDetail)
+		m.controls = append(m.controls, m.detailPanel)
+
+		// decoded jobs of the tube, shown in place of tubes stats
+		m.inspectPanel = &KeyValuePanel{BP: m}
+		m.inspectPanel.SetCloseFunc(m.closeJobInspector)
+		m.controls = append(m.controls, m.inspectPanel)
+
+		// jobs found by search, shown in place of tubes stats
+		m.searchGrid = &ScrollableGrid{
+			VScroller: true,
+			BP:        m,
+			Columns: []GridColumn{
+				{"id", AlignLeft, 10},
+				{"state", AlignLeft, 9},
+				{"pri", AlignLeft, 12},
+				{"age", AlignLeft, 8},
+				{"reserves", AlignLeft, 10},
+				{"body", AlignLeft, 50},
+			},
+		}
+		m.searchGrid.reset()
+		m.controls = append(m.controls, m.searchGrid)
+
+		// prompt drawn over the status line
+		m.prompt = &InputLine{BP: m}
+		m.controls = append(m.controls, m.prompt)
+
+		// connections per tube, shown in place of tubes stats
+		m.connStatsGrid = &ScrollableGrid{
+			VScroller: true,
+			Title:     fmt.Sprintf(connectionsTitle, 0, 0, 0),
+			BP:        m,
 			Columns: []GridColumn{
 				{"name", AlignLeft, 25},
-				{"current-jobs-urgent", AlignRight, 21},
-				{"current-jobs-ready", AlignRight, 21},
-				{"current-jobs-reserved", AlignRight, 25},
-				{"current-jobs-delayed", AlignRight, 21},
-				{"current-jobs-buried", AlignRight, 21},
-				{"total-jobs", AlignRight, 12},
+				{"status", AlignLeft, 14},
 				{"current-using", AlignRight, 15},
-				{"current-waiting", AlignRight, 17},
 				{"current-watching", AlignRight, 18},
-				{"pause", AlignRight, 7},
-				{"cmd-delete", AlignRight, 11},
-				{"cmd-pause-tube", AlignRight, 16},
-				{"pause-time-left", AlignRight, 17},
+				{"current-waiting", AlignRight, 17},
+				{"current-jobs-ready", AlignRight, 20},
+				{"current-jobs-reserved", AlignRight, 23},
 			},
 		}
-		m.tubesStatsGrid.SetVisible(true)
-		m.tubesStatsGrid.reset()
-		m.controls = append(m.controls, m.tubesStatsGrid)
+		m.connStatsGrid.SetCustomDrawFunc(func(index int, col, value string) (termbox.Attribute, termbox.Attribute) {
+			if col == "status" && value != "" {
+				return termbox.ColorRed | termbox.AttrBold, BGColor
+			}
+			return FGColor, BGColor
+		})
+		m.connStatsGrid.reset()
+		m.controls = append(m.controls, m.connStatsGrid)
 		if len(m.controls) > 0 {
 			m.controls[m.focusIndex].SetFocus(true)
 		}
 	}
-
-	m.startLoop(pollInterval)
 }
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/beanstalktest"
+	"github.com/kadekcipta/beanwalker/headless"
+	"github.com/kadekcipta/beanwalker/walker"
+	"github.com/kr/beanstalk"
+	"github.com/nsf/termbox-go"
+)
+
+// newTestFrame returns a frame connected to a fake server and drawn into a headless buffer
+// The server has a ready job in emails and a delayed one in sms, a sample is polled already
+func newTestFrame(t *testing.T, w, h int) (*beanstalktest.Server, *mainFrame) {
+	t.Helper()
+
+	s := beanstalktest.NewServer()
+	s.Put("emails", []byte(`{"user_id":42}`), 10, 0, time.Minute)
+	s.Put("sms", []byte("hello"), 2000, time.Hour, time.Minute)
+
+	m := &mainFrame{addr: s.Addr, dialer: &walker.Dialer{}}
+	if err := m.connect(); err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		m.disconnect()
+		s.Close()
+	})
+
+	// the address has a random port
+	hostInfo = "beanstalktest"
+	m.bp = headless.NewBuffer(w, h)
+	m.tasks = make(chan func())
+	m.initControls()
+	m.history = &timeline{live: true}
+	m.collectStats()
+	m.refresh()
+	return s, m
+}
+
+func frameBuffer(m *mainFrame) *headless.Buffer {
+	return m.bp.(*headless.Buffer)
+}
+
+// press runs the key as the event loop does, controls first then commands
+func press(m *mainFrame, key termbox.Key) {
+	if m.dispatchEvent(keyEvent(key)) {
+		m.refresh()
+		return
+	}
+	m.execCommand(key)
+}
+
+func TestFrameGolden(t *testing.T) {
+	_, m := newTestFrame(t, 100, 24)
+	headless.AssertGolden(t, frameBuffer(m), "frame")
+}
+
+func TestFrameNavigate(t *testing.T) {
+	_, m := newTestFrame(t, 100, 24)
+
+	// the focus moves from the system stats to the tubes, then the selection moves to emails
+	press(m, termbox.KeyTab)
+	if !m.tubesStatsGrid.Focused() || m.sysStatsGrid.Focused() {
+		t.Fatal("TAB didn't move the focus to the tubes grid")
+	}
+	press(m, termbox.KeyArrowDown)
+	if name := m.currentTubeName(); name != "emails" {
+		t.Errorf("selected tube = %q, want emails", name)
+	}
+	headless.AssertGolden(t, frameBuffer(m), "frame_navigate")
+}
+
+func TestFrameDrainingBadge(t *testing.T) {
+	s, m := newTestFrame(t, 100, 24)
+	s.SetDraining(true)
+	m.collectStats()
+	m.refresh()
+
+	if line := frameBuffer(m).Line(1); !strings.Contains(line, drainingBadge) {
+		t.Errorf("header %q has no drain mode badge", line)
+	}
+}
+
+func TestFrameTubeOperations(t *testing.T) {
+	s, m := newTestFrame(t, 100, 24)
+	s.Put("emails", []byte("second"), 10, 0, time.Minute)
+	press(m, termbox.KeyTab)
+	press(m, termbox.KeyArrowDown)
+
+	press(m, termbox.KeyF3)
+	m.collectStats()
+	stats := tubeStats(t, m, "emails")
+	if stats.CurrentJobsBuried != 2 || stats.CurrentJobsReady != 0 {
+		t.Fatalf("after bury: ready/buried = %d/%d, want 0/2", stats.CurrentJobsReady, stats.CurrentJobsBuried)
+	}
+
+	press(m, termbox.KeyF4)
+	m.collectStats()
+	if stats := tubeStats(t, m, "emails"); stats.CurrentJobsReady != 2 {
+		t.Fatalf("after kick: ready = %d, want 2", stats.CurrentJobsReady)
+	}
+
+	press(m, termbox.KeyF5)
+	m.collectStats()
+	if stats := tubeStats(t, m, "emails"); stats.CurrentJobsReady != 0 {
+		t.Fatalf("after delete: ready = %d, want 0", stats.CurrentJobsReady)
+	}
+	if line := frameBuffer(m).Line(23); !strings.HasPrefix(line, " emails: 2 ready jobs deleted ") {
+		t.Errorf("status line = %q", line)
+	}
+}
+
+// pollExtraStats polls a sample as collectStats does, with stats the fake server doesn't have
+// added to the system and the tubes
+func pollExtraStats(t *testing.T, m *mainFrame, system, tube map[string]string) {
+	t.Helper()
+
+	sample, err := m.client.Sample()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the pid and the uptime vary between runs
+	sample.System.PID, sample.System.Uptime = 4242, time.Hour
+	for k, v := range system {
+		sample.System.Raw[k] = v
+	}
+	for _, stats := range sample.Tubes {
+		for k, v := range tube {
+			stats.Raw[k] = v
+		}
+	}
+	m.history.add(sample, historyWindow)
+	m.showSample()
+	m.refresh()
+}
+
+func TestFrameTubeDetailGolden(t *testing.T) {
+	s, m := newTestFrame(t, 100, 40)
+	press(m, termbox.KeyTab)
+	press(m, termbox.KeyArrowDown)
+	press(m, termbox.KeyEnter)
+
+	// the second sample has deltas, another head job state and a stat missing from the columns
+	s.Put("emails", []byte("welcome\n  back"), 5, 0, time.Minute)
+	s.Put("emails", []byte("reminder"), 10, time.Hour, time.Minute)
+	pollExtraStats(t, m, nil, map[string]string{"current-jobs-archived": "3"})
+
+	if !m.detailPanel.Visible() {
+		t.Fatal("ENTER didn't show the tube detail")
+	}
+	headless.AssertGolden(t, frameBuffer(m), "frame_tube_detail")
+}
+
+func TestFrameKeyValueGolden(t *testing.T) {
+	_, m := newTestFrame(t, 140, 60)
+	pollExtraStats(t, m, map[string]string{"binlog-compactions": "12"}, nil)
+	press(m, termbox.KeyF2)
+
+	if m.sysStatsGrid.Layout != LayoutKeyValue {
+		t.Fatal("F2 didn't switch the system stats to the key/value layout")
+	}
+	headless.AssertGolden(t, frameBuffer(m), "frame_key_value")
+}
+
+func TestFrameConnectionsGolden(t *testing.T) {
+	s, m := newTestFrame(t, 100, 24)
+
+	// a worker watches sms, the ready job of emails has no watcher
+	conn, err := beanstalk.Dial("tcp", s.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, _, err := beanstalk.NewTubeSet(conn, "sms").Reserve(0); err == nil {
+		t.Fatal("reserved the delayed job of sms")
+	}
+	m.collectStats()
+	press(m, termbox.KeyF8)
+
+	if !m.connStatsGrid.Visible() {
+		t.Fatal("F8 didn't show the connections")
+	}
+	if !strings.Contains(frameBuffer(m).String(), noWatchersStatus) {
+		t.Errorf("emails isn't flagged %s", noWatchersStatus)
+	}
+	headless.AssertGolden(t, frameBuffer(m), "frame_connections")
+}
+
+func tubeStats(t *testing.T, m *mainFrame, name string) *walker.TubeStats {
+	t.Helper()
+
+	stats, err := m.client.TubeStats(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
@@ -1,7 +1,14 @@
 module github.com/kadekcipta/beanwalker
 
+go 1.18
+
 require (
 	github.com/kr/beanstalk v0.0.0-20180818045031-cae1762e4858
 	github.com/mattn/go-runewidth v0.0.3
 	github.com/nsf/termbox-go v0.0.0-20180819125858-b66b20ab708e
 )
+
+require (
+	golang.org/x/crypto v0.17.0
+	golang.org/x/sys v0.15.0 // indirect
+)
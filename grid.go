@@ -66,6 +66,8 @@ type ScrollableGrid struct {
 	VScroller      bool
 	Title          string
 	BP             BufferProxy
+	Layout         GridLayout
+	Groups         []string
 	visible        bool
 	focused        bool
 	dataStartY     int
@@ -76,6 +78,7 @@ type ScrollableGrid struct {
 	dataBounds     BufferRegion
 	data           [][]string
 	customDrawFunc CustomDrawFunc
+	groupFunc      GroupFunc
 	sync.RWMutex
 }
 
@@ -89,6 +92,22 @@ func (s *ScrollableGrid) CurrentRow() []string {
 	return nil
 }
 
+// SetTitle changes the title while the grid may be drawn
+func (s *ScrollableGrid) SetTitle(title string) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Title = title
+}
+
+// AppendColumns adds the columns after the existing ones
+func (s *ScrollableGrid) AppendColumns(cols ...GridColumn) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.Columns = append(s.Columns, cols...)
+}
+
 func (s *ScrollableGrid) SetCustomDrawFunc(f CustomDrawFunc) {
 	s.customDrawFunc = f
 }
@@ -148,7 +167,9 @@ func (s *ScrollableGrid) drawBorder() {
 		// top line
 		s.BP.SetCell(s.bounds.X+i, s.bounds.Y, '\u2500', fg, bg)
 		// heading bottom
-		s.BP.SetCell(s.bounds.X+i, s.bounds.Y+columnsOffset+1, '\u2500', fg, bg)
+		if s.Layout == LayoutTable {
+			s.BP.SetCell(s.bounds.X+i, s.bounds.Y+columnsOffset+1, '\u2500', fg, bg)
+		}
 		// bottom line
 		s.BP.SetCell(s.bounds.X+i, s.bounds.Y+s.bounds.H-1, '\u2500', fg, bg)
 	}
@@ -158,10 +179,12 @@ func (s *ScrollableGrid) drawBorder() {
 		// right line
 		s.BP.SetCell(s.bounds.X+s.bounds.W, s.bounds.Y+y, '\u2502', fg, bg)
 	}
-	// left heading junction
-	s.BP.SetCell(s.bounds.X, s.bounds.Y+columnsOffset+1, '\u251c', fg, bg)
-	// right heading junction
-	s.BP.SetCell(s.bounds.X+s.bounds.W, s.bounds.Y+columnsOffset+1, '\u2524', fg, bg)
+	if s.Layout == LayoutTable {
+		// left heading junction
+		s.BP.SetCell(s.bounds.X, s.bounds.Y+columnsOffset+1, '\u251c', fg, bg)
+		// right heading junction
+		s.BP.SetCell(s.bounds.X+s.bounds.W, s.bounds.Y+columnsOffset+1, '\u2524', fg, bg)
+	}
 	// bottom left
 	s.BP.SetCell(s.bounds.X, s.bounds.Y+s.bounds.H-1, '\u2514', fg, bg)
 	// bottom right
@@ -244,6 +267,13 @@ func (s *ScrollableGrid) drawBuffer() {
 	s.RLock()
 	defer s.RUnlock()
 
+	if s.Layout == LayoutKeyValue {
+		s.drawBorder()
+		s.drawTitle()
+		s.drawKeyValueData()
+		return
+	}
+
 	s.drawHeading()
 	s.drawBorder()
 	s.drawTitle()
@@ -374,6 +404,9 @@ func (s *ScrollableGrid) reset() {
 func (s *ScrollableGrid) scrollRight() {
 	s.hScrollPos++
 	max := len(s.Columns) - 1
+	if s.Layout == LayoutKeyValue {
+		max = s.kvColumnsCount()
+	}
 	if s.hScrollPos > max {
 		s.hScrollPos = max
 	}
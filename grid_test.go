@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kadekcipta/beanwalker/headless"
+	"github.com/nsf/termbox-go"
+)
+
+// newTestGrid returns a visible grid of the rows drawn into the buffer
+func newTestGrid(b *headless.Buffer, rows [][]string) *ScrollableGrid {
+	g := &ScrollableGrid{
+		VScroller: true,
+		Title:     "[ Tubes ]",
+		BP:        b,
+		Columns: []GridColumn{
+			{"name", AlignLeft, 12},
+			{"ready", AlignRight, 8},
+			{"buried", AlignRight, 8},
+			{"delayed", AlignRight, 9},
+		},
+	}
+	g.SetVisible(true)
+	g.reset()
+	g.UpdateData(rows)
+	g.Resize(BufferRegion{0, 0, b.W - 1, b.H})
+	return g
+}
+
+func testTubeRows(n int) [][]string {
+	rows := [][]string{}
+	for i := 0; i < n; i++ {
+		rows = append(rows, []string{fmt.Sprintf("tube-%02d", i), fmt.Sprint(i), fmt.Sprint(i * 2), fmt.Sprint(i * 3)})
+	}
+	return rows
+}
+
+func keyEvent(key termbox.Key) termbox.Event {
+	return termbox.Event{Type: termbox.EventKey, Key: key}
+}
+
+func TestGridGolden(t *testing.T) {
+	longName := append(testTubeRows(2), []string{"a-very-long-tube-name", "1", "2", "3"})
+
+	tests := []struct {
+		name  string
+		rows  [][]string
+		focus bool
+		keys  []termbox.Key
+	}{
+		{"grid_blurred", testTubeRows(3), false, nil},
+		{"grid_focused", testTubeRows(3), true, nil},
+		{"grid_scroll_down", testTubeRows(12), true, []termbox.Key{
+			termbox.KeyArrowDown, termbox.KeyArrowDown, termbox.KeyArrowDown, termbox.KeyArrowDown,
+			termbox.KeyArrowDown, termbox.KeyArrowDown, termbox.KeyArrowDown, termbox.KeyArrowDown,
+		}},
+		{"grid_scroll_up", testTubeRows(12), true, []termbox.Key{
+			termbox.KeyArrowDown, termbox.KeyArrowDown, termbox.KeyArrowDown, termbox.KeyArrowDown,
+			termbox.KeyArrowDown, termbox.KeyArrowDown, termbox.KeyArrowDown, termbox.KeyArrowDown,
+			termbox.KeyArrowUp, termbox.KeyArrowUp, termbox.KeyArrowUp, termbox.KeyArrowUp,
+			termbox.KeyArrowUp, termbox.KeyArrowUp, termbox.KeyArrowUp,
+		}},
+		{"grid_scroll_right", testTubeRows(3), true, []termbox.Key{termbox.KeyArrowRight, termbox.KeyArrowRight}},
+		{"grid_truncated", longName, false, nil},
+		{"grid_truncated_hint", longName, true, []termbox.Key{termbox.KeyArrowDown, termbox.KeyArrowDown}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := headless.NewBuffer(32, 10)
+			g := newTestGrid(b, tt.rows)
+			g.SetFocus(tt.focus)
+			for _, key := range tt.keys {
+				if !g.HandleEvent(keyEvent(key)) {
+					t.Fatalf("key %v not handled", key)
+				}
+			}
+
+			b.Clear(termbox.ColorDefault, termbox.ColorDefault)
+			g.Redraw()
+			headless.AssertGolden(t, b, tt.name)
+		})
+	}
+}
+
+func TestGridSelection(t *testing.T) {
+	g := newTestGrid(headless.NewBuffer(32, 10), testTubeRows(12))
+	g.SetFocus(true)
+
+	for i := 0; i < 20; i++ {
+		g.HandleEvent(keyEvent(termbox.KeyArrowDown))
+	}
+	if row := g.CurrentRow(); row[0] != "tube-11" {
+		t.Errorf("selection past the end = %v, want tube-11", row)
+	}
+
+	// rows of another width are dropped, the selection stays in range
+	g.UpdateData([][]string{{"only", "1", "2", "3"}, {"bad"}})
+	if row := g.CurrentRow(); row[0] != "only" {
+		t.Errorf("selection after update = %v, want only", row)
+	}
+}
+
+func TestColumnFormat(t *testing.T) {
+	tests := []struct {
+		col  GridColumn
+		in   string
+		want string
+	}{
+		{GridColumn{"name", AlignLeft, 8}, "sms", "sms     "},
+		{GridColumn{"ready", AlignRight, 8}, "42", "      42"},
+		{GridColumn{"name", AlignLeft, 8}, "newsletters", "newsl..."},
+		{GridColumn{"name", AlignLeft, 8}, "exactly8", "exactly8"},
+	}
+	for _, tt := range tests {
+		if got := tt.col.Format(tt.in); got != tt.want {
+			t.Errorf("Format(%q) width %d = %q, want %q", tt.in, tt.col.Width, got, tt.want)
+		}
+	}
+}
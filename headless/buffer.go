@@ -0,0 +1,169 @@
+// Package headless provides a recording buffer proxy, so the user interface can be drawn and
+// inspected without a terminal.
+package headless
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nsf/termbox-go"
+)
+
+// Cell is a single recorded character with its attributes
+type Cell struct {
+	Ch rune
+	Fg termbox.Attribute
+	Bg termbox.Attribute
+}
+
+// Buffer records the drawing into a fixed size cell matrix
+// It satisfies the buffer proxy interface used by the controls
+type Buffer struct {
+	W, H    int
+	Cells   [][]Cell
+	Flushes int
+}
+
+// NewBuffer returns a cleared buffer of the size
+func NewBuffer(w, h int) *Buffer {
+	b := &Buffer{W: w, H: h}
+	b.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	return b
+}
+
+func (b *Buffer) Clear(fg, bg termbox.Attribute) {
+	b.Cells = make([][]Cell, b.H)
+	for y := range b.Cells {
+		b.Cells[y] = make([]Cell, b.W)
+		for x := range b.Cells[y] {
+			b.Cells[y][x] = Cell{' ', fg, bg}
+		}
+	}
+}
+
+// SetCell records the cell, cells outside of the buffer are ignored like termbox does
+func (b *Buffer) SetCell(x, y int, ch rune, fg, bg termbox.Attribute) {
+	if x < 0 || y < 0 || x >= b.W || y >= b.H {
+		return
+	}
+	b.Cells[y][x] = Cell{ch, fg, bg}
+}
+
+func (b *Buffer) WriteText(x, y int, fg, bg termbox.Attribute, s string) {
+	for _, c := range s {
+		b.SetCell(x, y, c, fg, bg)
+		x++
+	}
+}
+
+func (b *Buffer) Size() (int, int) {
+	return b.W, b.H
+}
+
+// Flush only counts the calls, the cells are always up to date
+func (b *Buffer) Flush() error {
+	b.Flushes++
+	return nil
+}
+
+// Cell returns the recorded cell, zero cell when outside of the buffer
+func (b *Buffer) Cell(x, y int) Cell {
+	if x < 0 || y < 0 || x >= b.W || y >= b.H {
+		return Cell{}
+	}
+	return b.Cells[y][x]
+}
+
+// Line returns the text of the row without trailing spaces
+func (b *Buffer) Line(y int) string {
+	if y < 0 || y >= b.H {
+		return ""
+	}
+	runes := make([]rune, b.W)
+	for x, c := range b.Cells[y] {
+		runes[x] = c.Ch
+	}
+	return strings.TrimRight(string(runes), " ")
+}
+
+// String returns the text of the buffer, one line per row
+func (b *Buffer) String() string {
+	lines := make([]string, b.H)
+	for y := range lines {
+		lines[y] = b.Line(y)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Snapshot returns the text followed by the attributes of every cell
+// Attributes are written as one letter per cell, the letters are explained by the legend at the end
+func (b *Buffer) Snapshot() string {
+	type style struct {
+		fg, bg termbox.Attribute
+	}
+
+	codes := map[style]byte{}
+	styles := []style{}
+	attrs := make([]string, b.H)
+	for y, row := range b.Cells {
+		line := make([]byte, b.W)
+		for x, c := range row {
+			st := style{c.Fg, c.Bg}
+			code, ok := codes[st]
+			if !ok {
+				code = styleCode(len(styles))
+				codes[st] = code
+				styles = append(styles, st)
+			}
+			line[x] = code
+		}
+		attrs[y] = string(line)
+	}
+
+	sb := &strings.Builder{}
+	sb.WriteString("-- text --\n")
+	sb.WriteString(b.String())
+	sb.WriteString("-- attributes --\n")
+	for _, line := range attrs {
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString("-- legend --\n")
+	legend := []string{}
+	for _, st := range styles {
+		legend = append(legend, fmt.Sprintf("%c fg=%s bg=%s", codes[st], AttributeName(st.fg), AttributeName(st.bg)))
+	}
+	sort.Strings(legend)
+	sb.WriteString(strings.Join(legend, "\n") + "\n")
+
+	return sb.String()
+}
+
+func styleCode(i int) byte {
+	const codes = ".abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	if i < len(codes) {
+		return codes[i]
+	}
+	return '?'
+}
+
+var colorNames = []string{"default", "black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+// AttributeName describes the attribute as color name followed by the styles, e.g. red|bold
+func AttributeName(a termbox.Attribute) string {
+	color := a & 0x1ff
+	name := fmt.Sprintf("color%d", color)
+	if int(color) < len(colorNames) {
+		name = colorNames[color]
+	}
+	if a&termbox.AttrBold != 0 {
+		name += "|bold"
+	}
+	if a&termbox.AttrUnderline != 0 {
+		name += "|underline"
+	}
+	if a&termbox.AttrReverse != 0 {
+		name += "|reverse"
+	}
+	return name
+}
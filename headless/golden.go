@@ -0,0 +1,63 @@
+package headless
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// UpdateEnv names the environment variable which makes AssertGolden rewrite the golden files
+const UpdateEnv = "BEANWALKER_UPDATE_GOLDEN"
+
+// CompareGolden compares the snapshot with the golden file content
+// The golden file is written instead when update is true
+func CompareGolden(path, got string, update bool) error {
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, []byte(got), 0644)
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s doesn't exist, set %s=1 to create it", path, UpdateEnv)
+	}
+	if err != nil {
+		return err
+	}
+
+	if string(want) == got {
+		return nil
+	}
+
+	wantLines := strings.Split(string(want), "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Errorf("%s:%d differs\nwant: %q\ngot:  %q", path, i+1, w, g)
+		}
+	}
+	return fmt.Errorf("%s differs", path)
+}
+
+// AssertGolden fails the test when the buffer snapshot differs from testdata/<name>.golden
+// Set BEANWALKER_UPDATE_GOLDEN=1 to accept the current drawing
+func AssertGolden(t testing.TB, b *Buffer, name string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if err := CompareGolden(path, b.Snapshot(), os.Getenv(UpdateEnv) != ""); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+)
+
+// InputLine is a single line text input drawn as a label followed by the text
+// Enter submits the text and Esc cancels, every key is consumed while it is visible
+type InputLine struct {
+	Label      string
+	BP         BufferProxy
+	visible    bool
+	focused    bool
+	text       []rune
+	cursor     int
+	bounds     BufferRegion
+	submitFunc func(string)
+	cancelFunc func()
+	sync.RWMutex
+}
+
+// Ask clears the text and sets the callbacks of the next submit or cancel
+func (i *InputLine) Ask(label string, submit func(string), cancel func()) {
+	i.Lock()
+	i.Label = label
+	i.text = nil
+	i.cursor = 0
+	i.submitFunc = submit
+	i.cancelFunc = cancel
+	i.Unlock()
+}
+
+// Text returns the current text
+func (i *InputLine) Text() string {
+	i.RLock()
+	defer i.RUnlock()
+
+	return string(i.text)
+}
+
+func (i *InputLine) insert(r rune) {
+	i.text = append(i.text[:i.cursor], append([]rune{r}, i.text[i.cursor:]...)...)
+	i.cursor++
+}
+
+func (i *InputLine) HandleEvent(ev termbox.Event) bool {
+	if !i.visible || ev.Type != termbox.EventKey {
+		return false
+	}
+
+	i.Lock()
+	switch ev.Key {
+	case termbox.KeyEnter:
+		f, text := i.submitFunc, string(i.text)
+		i.Unlock()
+		if f != nil {
+			f(text)
+		}
+		return true
+
+	case termbox.KeyEsc:
+		f := i.cancelFunc
+		i.Unlock()
+		if f != nil {
+			f()
+		}
+		return true
+
+	case termbox.KeyBackspace, termbox.KeyBackspace2:
+		if i.cursor > 0 {
+			i.text = append(i.text[:i.cursor-1], i.text[i.cursor:]...)
+			i.cursor--
+		}
+
+	case termbox.KeyDelete, termbox.KeyCtrlD:
+		if i.cursor < len(i.text) {
+			i.text = append(i.text[:i.cursor], i.text[i.cursor+1:]...)
+		}
+
+	case termbox.KeyArrowLeft:
+		if i.cursor > 0 {
+			i.cursor--
+		}
+
+	case termbox.KeyArrowRight:
+		if i.cursor < len(i.text) {
+			i.cursor++
+		}
+
+	case termbox.KeyHome, termbox.KeyCtrlA:
+		i.cursor = 0
+
+	case termbox.KeyEnd, termbox.KeyCtrlE:
+		i.cursor = len(i.text)
+
+	case termbox.KeyCtrlU:
+		i.text = nil
+		i.cursor = 0
+
+	case termbox.KeySpace:
+		i.insert(' ')
+
+	default:
+		if ev.Ch != 0 {
+			i.insert(ev.Ch)
+		}
+	}
+	i.Unlock()
+
+	return true
+}
+
+func (i *InputLine) drawBuffer() {
+	i.RLock()
+	defer i.RUnlock()
+
+	for x := 0; x < i.bounds.W; x++ {
+		i.BP.SetCell(i.bounds.X+x, i.bounds.Y, ' ', FGColor, BGColor)
+	}
+	i.BP.WriteText(i.bounds.X, i.bounds.Y, termbox.ColorYellow|termbox.AttrBold, BGColor, i.Label)
+
+	// keep the cursor in view by scrolling the text to the left
+	x := i.bounds.X + runewidth.StringWidth(i.Label)
+	avail := i.bounds.W - (x - i.bounds.X) - 1
+	start := 0
+	if avail > 0 && i.cursor > avail {
+		start = i.cursor - avail
+	}
+	for pos := start; pos <= len(i.text) && x < i.bounds.X+i.bounds.W; pos++ {
+		ch := ' '
+		if pos < len(i.text) {
+			ch = i.text[pos]
+		}
+		fg, bg := FGColor, BGColor
+		if pos == i.cursor && i.focused {
+			fg |= termbox.AttrReverse
+		}
+		i.BP.SetCell(x, i.bounds.Y, ch, fg, bg)
+		x += runewidth.RuneWidth(ch)
+	}
+}
+
+func (i *InputLine) Resize(bounds BufferRegion) {
+	i.bounds = bounds
+	i.Redraw()
+}
+
+func (i *InputLine) Redraw() {
+	if i.visible {
+		i.drawBuffer()
+	}
+}
+
+func (i *InputLine) SetFocus(v bool) {
+	i.focused = v
+	i.Redraw()
+}
+
+func (i *InputLine) Focused() bool {
+	return i.focused
+}
+
+func (i *InputLine) SetVisible(v bool) {
+	i.visible = v
+	i.Redraw()
+}
+
+func (i *InputLine) Visible() bool {
+	return i.visible
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+)
+
+type GridLayout int
+
+const (
+	// LayoutTable draws one row per data item and one column per stat
+	LayoutTable GridLayout = iota
+	// LayoutKeyValue draws the first data row as grouped key/value pairs flowing in columns
+	LayoutKeyValue
+)
+
+// GroupFunc returns the group name of the column, used by key/value layout
+type GroupFunc func(string) string
+
+// kvLine is a single line of key/value layout, line without column index is a group heading
+type kvLine struct {
+	text   string
+	column int
+}
+
+// SetGroupFunc sets the ordered group names and the function to categorize the columns
+// Columns of unlisted groups are appended after the listed ones
+func (s *ScrollableGrid) SetGroupFunc(groups []string, f GroupFunc) {
+	s.Groups = groups
+	s.groupFunc = f
+}
+
+// ToggleLayout switches between table and key/value layout
+func (s *ScrollableGrid) ToggleLayout() {
+	s.Lock()
+	if s.Layout == LayoutTable {
+		s.Layout = LayoutKeyValue
+	} else {
+		s.Layout = LayoutTable
+	}
+	s.hScrollPos = 1
+	s.Unlock()
+
+	s.Redraw()
+}
+
+func (s *ScrollableGrid) kvLines() []kvLine {
+	order := append([]string{}, s.Groups...)
+	members := map[string][]int{}
+	for i, col := range s.Columns {
+		group := ""
+		if s.groupFunc != nil {
+			group = s.groupFunc(col.Name)
+		}
+		if _, ok := members[group]; !ok {
+			listed := false
+			for _, g := range order {
+				listed = listed || g == group
+			}
+			if !listed {
+				order = append(order, group)
+			}
+		}
+		members[group] = append(members[group], i)
+	}
+
+	lines := []kvLine{}
+	for _, g := range order {
+		if len(members[g]) == 0 {
+			continue
+		}
+		lines = append(lines, kvLine{g, -1})
+		for _, i := range members[g] {
+			lines = append(lines, kvLine{s.Columns[i].Name, i})
+		}
+	}
+
+	return lines
+}
+
+// kvColumnWidth returns the width of a flowing column of key/value layout
+func (s *ScrollableGrid) kvColumnWidth() (int, int) {
+	kw, vw := 0, 0
+	for i, col := range s.Columns {
+		if l := runewidth.StringWidth(col.Name); l > kw {
+			kw = l
+		}
+		if len(s.data) > 0 && i < len(s.data[0]) {
+			if l := runewidth.StringWidth(s.data[0][i]); l > vw {
+				vw = l
+			}
+		}
+	}
+	return kw + 1, vw + 3
+}
+
+// kvFlow splits the lines into columns of the height, group heading is never left at a column bottom
+func kvFlow(lines []kvLine, height int) [][]kvLine {
+	if height < 2 {
+		return nil
+	}
+	columns := [][]kvLine{}
+	current := []kvLine{}
+	for _, line := range lines {
+		full := len(current) == height || (line.column < 0 && len(current) == height-1)
+		if full {
+			columns = append(columns, current)
+			current = []kvLine{}
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		columns = append(columns, current)
+	}
+	return columns
+}
+
+// kvColumnsCount returns the number of flowing columns for the current bounds
+func (s *ScrollableGrid) kvColumnsCount() int {
+	return len(kvFlow(s.kvLines(), s.bounds.H-2))
+}
+
+// PreferredHeight returns the grid height needed to show all key/value pairs within the width
+func (s *ScrollableGrid) PreferredHeight(width int) int {
+	s.RLock()
+	defer s.RUnlock()
+
+	lines := s.kvLines()
+	kw, vw := s.kvColumnWidth()
+	for h := 2; h < len(lines); h++ {
+		if len(kvFlow(lines, h))*(kw+vw) <= width-1 {
+			return h + 2
+		}
+	}
+	return len(lines) + 2
+}
+
+func (s *ScrollableGrid) drawKeyValueData() {
+	bounds := BufferRegion{s.bounds.X + 1, s.bounds.Y + 1, s.bounds.W - 1, s.bounds.H - 2}
+	kw, vw := s.kvColumnWidth()
+	columns := kvFlow(s.kvLines(), bounds.H)
+
+	dx := bounds.X
+	c := s.hScrollPos - 1
+	for ; c < len(columns); c++ {
+		if dx+kw+vw > bounds.X+bounds.W {
+			break
+		}
+		for y, line := range columns[c] {
+			if line.column < 0 {
+				s.BP.WriteText(dx, bounds.Y+y, termbox.ColorRed|termbox.AttrBold, BGColor, line.text)
+				continue
+			}
+			value := ""
+			if len(s.data) > 0 && line.column < len(s.data[0]) {
+				value = s.data[0][line.column]
+			}
+			fg, bg := FGColor, BGColor
+			if s.customDrawFunc != nil {
+				fg, bg = s.customDrawFunc(0, line.text, value)
+			}
+			s.BP.WriteText(dx, bounds.Y+y, FGColor, BGColor, line.text)
+			s.BP.WriteText(dx+kw+vw-3-runewidth.StringWidth(value), bounds.Y+y, fg|termbox.AttrBold, bg, value)
+		}
+		dx += kw + vw
+	}
+
+	if s.Focused() {
+		if s.hScrollPos > 1 {
+			s.BP.WriteText(s.bounds.X, s.bounds.Y+1, FGColor|termbox.AttrBold, BGColor, "\u2190")
+		}
+		if c < len(columns) {
+			s.BP.WriteText(s.bounds.X+s.bounds.W, s.bounds.Y+1, FGColor|termbox.AttrBold, BGColor, "\u2192")
+		}
+	}
+}
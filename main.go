@@ -1,26 +1,75 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
 	"os"
 	"runtime"
 	"strings"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+const (
+	hostUsage      = "beanstalkd host, or an address like unix:///run/beanstalkd.sock or tcp://[::1]:11300"
+	defaultTimeout = 10 * time.Second
+	configUsage    = "JSON configuration file, e.g. connection profiles and job body decoders per tube"
+	profileUsage   = "connection profile of the -config file, the flags given take precedence"
 )
 
 var (
 	bsHost       string
 	bsPort       int
+	dial         dialFlags
 	pollInterval int
+	humanize     bool
+	configPath   string
+	profile      string
+	recordPath   string
+	replayPath   string
+	httpAddr     string
 )
 
 func main() {
 
 	runtime.GOMAXPROCS(runtime.NumCPU())
 
-	flag.StringVar(&bsHost, "h", "127.0.0.1", "beanstalkd host")
-	flag.IntVar(&bsPort, "p", 11300, "beanstalkd port")
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err.Error())
+				if _, ok := err.(usageError); ok {
+					os.Exit(2)
+				}
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	flag.StringVar(&bsHost, "h", "127.0.0.1", hostUsage)
+	flag.IntVar(&bsPort, "p", walker.DefaultPort, "beanstalkd port")
+	dial.register(flag.CommandLine)
 	flag.IntVar(&pollInterval, "i", 2, "refresh interval in seconds and must be greater than 2 seconds")
+	flag.BoolVar(&humanize, "humanize", false, "start with humanized values, e.g. 1.2k, 3h12m and 64KiB, F9 toggles")
+	flag.StringVar(&configPath, "config", "", configUsage)
+	flag.StringVar(&profile, "profile", "", profileUsage)
+	flag.StringVar(&recordPath, "record", "", "append every polled sample to the file, for -replay")
+	flag.StringVar(&replayPath, "replay", "", "show the samples recorded with -record instead of connecting")
+	flag.StringVar(&httpAddr, "http", "", "serve the HTTP JSON API and the dashboard on the address along with the user interface, e.g. 127.0.0.1:8080")
 	flag.Parse()
+
+	config, err := loadConfig(configPath)
+	if err == nil {
+		err = config.applyProfile(flag.CommandLine, profile, "")
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(-1)
+	}
+
 	if strings.TrimSpace(bsHost) == "" {
 		flag.PrintDefaults()
 		os.Exit(-1)
@@ -30,6 +79,33 @@ func main() {
 		pollInterval = 2
 	}
 
-	mainFrame := &mainFrame{}
-	mainFrame.show(bsHost, bsPort, pollInterval)
+	addr := walker.HostPortAddr(bsHost, bsPort)
+	dialer := dial.dialer()
+	mainFrame := &mainFrame{formatter: Formatter{Humanize: humanize}, config: config, dialer: dialer}
+	if replayPath != "" {
+		if mainFrame.replay, err = loadTimeline(replayPath); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(-1)
+		}
+	}
+	if httpAddr != "" {
+		api, err := listenAPI(httpAddr, dialer, addr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(-1)
+		}
+		// the dashboard follows the samples polled by the user interface
+		mainFrame.hub = api.hub
+		go api.Serve()
+	}
+	if recordPath != "" {
+		f, err := os.OpenFile(recordPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(-1)
+		}
+		defer f.Close()
+		mainFrame.recorder = json.NewEncoder(f)
+	}
+	mainFrame.show(addr, pollInterval)
 }
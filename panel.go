@@ -0,0 +1,233 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-runewidth"
+	"github.com/nsf/termbox-go"
+)
+
+// KeyValueRow represents a single line of KeyValuePanel
+// Row without value is drawn as section heading
+type KeyValueRow struct {
+	Key   string
+	Value string
+	Note  string
+}
+
+// KeyValuePanel presents key/value pairs vertically, one pair per line
+// Vertical scrolling is provided by arrow keys and Esc closes the panel
+type KeyValuePanel struct {
+	Title      string
+	BP         BufferProxy
+	visible    bool
+	focused    bool
+	vScrollPos int
+	bounds     BufferRegion
+	dataBounds BufferRegion
+	rows       []KeyValueRow
+	closeFunc  func()
+	sync.RWMutex
+}
+
+// SetCloseFunc sets the callback invoked when the panel is closed by Esc key
+func (p *KeyValuePanel) SetCloseFunc(f func()) {
+	p.closeFunc = f
+}
+
+func (p *KeyValuePanel) keyWidth() int {
+	w := 0
+	for _, r := range p.rows {
+		if l := runewidth.StringWidth(r.Key); l > w {
+			w = l
+		}
+	}
+	return w + 2
+}
+
+func (p *KeyValuePanel) valueWidth() int {
+	w := 0
+	for _, r := range p.rows {
+		if l := runewidth.StringWidth(r.Value); l > w {
+			w = l
+		}
+	}
+	return w + 2
+}
+
+func (p *KeyValuePanel) drawBorder() {
+	fg := FGColor
+	bg := BGColor
+	if p.Focused() {
+		fg |= termbox.AttrBold
+	}
+
+	p.BP.SetCell(p.bounds.X, p.bounds.Y, '\u250c', fg, bg)
+	p.BP.SetCell(p.bounds.X+p.bounds.W, p.bounds.Y, '\u2510', fg, bg)
+	for i := 1; i < p.bounds.W; i++ {
+		p.BP.SetCell(p.bounds.X+i, p.bounds.Y, '\u2500', fg, bg)
+		p.BP.SetCell(p.bounds.X+i, p.bounds.Y+p.bounds.H-1, '\u2500', fg, bg)
+	}
+	for y := 1; y < p.bounds.H-1; y++ {
+		p.BP.SetCell(p.bounds.X, p.bounds.Y+y, '\u2502', fg, bg)
+		p.BP.SetCell(p.bounds.X+p.bounds.W, p.bounds.Y+y, '\u2502', fg, bg)
+	}
+	p.BP.SetCell(p.bounds.X, p.bounds.Y+p.bounds.H-1, '\u2514', fg, bg)
+	p.BP.SetCell(p.bounds.X+p.bounds.W, p.bounds.Y+p.bounds.H-1, '\u2518', fg, bg)
+}
+
+func (p *KeyValuePanel) drawTitle() {
+	cx := p.bounds.X + (p.bounds.W-runewidth.StringWidth(p.Title))/2
+	p.BP.WriteText(cx, p.bounds.Y, FGColor, FGColor, strings.ToUpper(p.Title))
+}
+
+func (p *KeyValuePanel) drawHints() {
+	if !p.Focused() {
+		return
+	}
+	cx := (p.bounds.X + p.bounds.W) / 2
+	if p.vScrollPos > 0 {
+		p.BP.WriteText(cx, p.bounds.Y+p.bounds.H-1, FGColor|termbox.AttrBold, BGColor, " \u2191 ")
+	}
+	if p.vScrollPos+p.dataBounds.H < len(p.rows) {
+		p.BP.WriteText(cx+3, p.bounds.Y+p.bounds.H-1, FGColor|termbox.AttrBold, BGColor, " \u2193 ")
+	}
+	p.BP.WriteText(p.bounds.X+2, p.bounds.Y+p.bounds.H-1, FGColor, BGColor, " ESC Close ")
+}
+
+// clip cuts the text to fit the available width
+func clip(s string, w int) string {
+	if w <= 0 {
+		return ""
+	}
+	if runewidth.StringWidth(s) <= w {
+		return s
+	}
+	return runewidth.Truncate(s, w, "...")
+}
+
+func (p *KeyValuePanel) drawData() {
+	kw := p.keyWidth()
+	vw := p.valueWidth()
+	for i := 0; i < p.dataBounds.H; i++ {
+		index := p.vScrollPos + i
+		if index >= len(p.rows) {
+			break
+		}
+		row := p.rows[index]
+		y := p.dataBounds.Y + i
+		x := p.dataBounds.X
+		if row.Value == "" && row.Note == "" {
+			p.BP.WriteText(x, y, termbox.ColorRed|termbox.AttrBold, BGColor, clip(row.Key, p.dataBounds.W))
+			continue
+		}
+		p.BP.WriteText(x, y, FGColor, BGColor, clip(row.Key, p.dataBounds.W))
+		p.BP.WriteText(x+kw, y, FGColor|termbox.AttrBold, BGColor, clip(row.Value, p.dataBounds.W-kw))
+
+		noteColor := FGColor
+		switch {
+		case strings.HasPrefix(row.Note, "+"):
+			noteColor = termbox.ColorGreen
+		case strings.HasPrefix(row.Note, "-"):
+			noteColor = termbox.ColorRed
+		}
+		p.BP.WriteText(x+kw+vw, y, noteColor, BGColor, clip(row.Note, p.dataBounds.W-kw-vw))
+	}
+}
+
+func (p *KeyValuePanel) drawBuffer() {
+	p.RLock()
+	defer p.RUnlock()
+
+	p.drawBorder()
+	p.drawTitle()
+	p.drawData()
+	p.drawHints()
+}
+
+func (p *KeyValuePanel) adjustScrollPos() {
+	max := len(p.rows) - p.dataBounds.H
+	if p.vScrollPos > max {
+		p.vScrollPos = max
+	}
+	if p.vScrollPos < 0 {
+		p.vScrollPos = 0
+	}
+}
+
+// UpdateData replaces the rows while keeping the scroll position
+func (p *KeyValuePanel) UpdateData(rows []KeyValueRow) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.rows = rows[:]
+	p.adjustScrollPos()
+}
+
+func (p *KeyValuePanel) Resize(bounds BufferRegion) {
+	p.bounds = bounds
+	p.dataBounds = BufferRegion{
+		bounds.X + 2,
+		bounds.Y + 1,
+		bounds.W - 3,
+		bounds.H - 2,
+	}
+	p.adjustScrollPos()
+	p.Redraw()
+}
+
+func (p *KeyValuePanel) HandleEvent(ev termbox.Event) bool {
+	if !p.visible {
+		return false
+	}
+
+	switch ev.Type {
+	case termbox.EventKey:
+		switch ev.Key {
+		case termbox.KeyArrowUp:
+			p.vScrollPos--
+			p.adjustScrollPos()
+			return true
+
+		case termbox.KeyArrowDown:
+			p.vScrollPos++
+			p.adjustScrollPos()
+			return true
+
+		case termbox.KeyEsc:
+			if p.closeFunc != nil {
+				p.closeFunc()
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *KeyValuePanel) Redraw() {
+	if p.visible {
+		p.drawBuffer()
+	}
+}
+
+func (p *KeyValuePanel) SetFocus(v bool) {
+	p.focused = v
+	if p.visible {
+		p.Redraw()
+	}
+}
+
+func (p *KeyValuePanel) Focused() bool {
+	return p.focused
+}
+
+func (p *KeyValuePanel) SetVisible(v bool) {
+	p.visible = v
+	p.Redraw()
+}
+
+func (p *KeyValuePanel) Visible() bool {
+	return p.visible
+}
@@ -0,0 +1,468 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JobFields lists the job stats a filter can refer to
+var JobFields = []string{"id", "tube", "state", "pri", "age", "delay", "ttr", "time-left", "file", "reserves", "timeouts", "releases", "buries", "kicks"}
+
+// Filter reports whether a job matches a predicate over its stats and body
+//
+// A predicate is made of conditions joined by and, or, not and parentheses, e.g.
+//
+//	age > 1d and releases >= 5
+//	body contains 'legacy' or .version < 3
+//
+// A condition on a job stat uses one of the operators == != < <= > >= =~, durations are compared in seconds
+// and values may be written with s, m, h or d units. body contains 'text' and body =~ /regexp/ match the
+// whole body. Other conditions are body expressions as accepted by Parse, text containing spaces around and,
+// or or parentheses is quoted.
+type Filter interface {
+	Match(fields map[string]interface{}, body []byte) bool
+}
+
+// ParseFilter compiles the predicate
+func ParseFilter(expr string) (Filter, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty predicate")
+	}
+
+	p := &filterParser{expr: expr, tokens: tokens}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %s", p.tokens[p.pos].text)
+	}
+	return f, nil
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenRegexp
+	tokenOperator
+	tokenParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// position of the token in the predicate, quotes included
+	start, end int
+}
+
+// tokenize splits the predicate into words, quoted strings, /regexps/, operators and parentheses
+func tokenize(s string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{tokenParen, string(c), i, i + 1})
+			i++
+
+		case c == '\'' || c == '"':
+			end := i + 1
+			for end < len(s) && s[end] != c {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string %s", s[i:])
+			}
+			text := strings.NewReplacer(`\\`, `\`, `\`+string(c), string(c)).Replace(s[i+1 : end])
+			tokens = append(tokens, token{tokenString, text, i, end + 1})
+			i = end + 1
+
+		case c == '/':
+			end := i + 1
+			for end < len(s) && s[end] != '/' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated regular expression %s", s[i:])
+			}
+			end++
+			for end < len(s) && s[end] >= 'a' && s[end] <= 'z' {
+				end++
+			}
+			tokens = append(tokens, token{tokenRegexp, s[i:end], i, end})
+			i = end
+
+		case strings.IndexByte("=!<>~", c) >= 0:
+			end := i + 1
+			if end < len(s) && strings.IndexByte("=~", s[end]) >= 0 {
+				end++
+			}
+			op := s[i:end]
+			if op == "=" {
+				op = "=="
+			}
+			tokens = append(tokens, token{tokenOperator, op, i, end})
+			i = end
+
+		default:
+			end := i
+			for end < len(s) && strings.IndexByte(" \t()=!<>~'\"", s[end]) < 0 {
+				end++
+			}
+			tokens = append(tokens, token{tokenWord, s[i:end], i, end})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	expr   string
+	tokens []token
+	pos    int
+}
+
+func (p *filterParser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *filterParser) next() (*token, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of predicate")
+	}
+	p.pos++
+	return t, nil
+}
+
+// keyword reports whether the next token is the word and consumes it
+func (p *filterParser) keyword(word string) bool {
+	if t := p.peek(); t != nil && t.kind == tokenWord && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) or() (Filter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = orFilter{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) and() (Filter, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = andFilter{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) unary() (Filter, error) {
+	if p.keyword("not") {
+		f, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return notFilter{f}, nil
+	}
+
+	if t := p.peek(); t != nil && t.kind == tokenParen && t.text == "(" {
+		p.pos++
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if t, err := p.next(); err != nil || t.text != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return f, nil
+	}
+
+	return p.condition()
+}
+
+// condition reads the tokens up to the next and, or or closing parenthesis
+func (p *filterParser) condition() (Filter, error) {
+	start := p.pos
+	for p.pos < len(p.tokens) && !p.boundary(p.tokens[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		if t := p.peek(); t != nil {
+			return nil, fmt.Errorf("expected a condition, found %s", t.text)
+		}
+		return nil, fmt.Errorf("unexpected end of predicate")
+	}
+	tokens := p.tokens[start:p.pos]
+
+	first := tokens[0]
+	hasOperator := false
+	for _, t := range tokens {
+		hasOperator = hasOperator || t.kind == tokenOperator
+	}
+	switch {
+	case first.kind == tokenWord && isJobField(first.text) && len(tokens) > 1 && tokens[1].kind == tokenOperator:
+		return statCondition(tokens)
+
+	case first.kind == tokenWord && strings.EqualFold(first.text, "body") && len(tokens) > 1:
+		return bodyKeywordCondition(tokens)
+
+	case first.kind == tokenWord && hasOperator && !strings.HasPrefix(first.text, ".") && !strings.HasPrefix(first.text, "["):
+		return nil, fmt.Errorf("unknown job stat %s, expected one of %s or a body path like .user.id", first.text, strings.Join(JobFields, " "))
+
+	case len(tokens) == 1 && first.kind == tokenString:
+		return bodyCondition{substringMatcher(first.text)}, nil
+	}
+
+	m, err := Parse(p.expr[first.start:tokens[len(tokens)-1].end])
+	if err != nil {
+		return nil, err
+	}
+	return bodyCondition{m}, nil
+}
+
+// boundary reports whether the token ends a condition
+func (p *filterParser) boundary(t token) bool {
+	if t.kind == tokenParen {
+		return t.text == ")"
+	}
+	return t.kind == tokenWord && (strings.EqualFold(t.text, "and") || strings.EqualFold(t.text, "or"))
+}
+
+// statCondition compiles the comparison of a job stat, e.g. age > 1d
+func statCondition(tokens []token) (Filter, error) {
+	c := &condition{subject: tokens[0].text, op: tokens[1].text}
+	if !isOperator(c.op) {
+		return nil, fmt.Errorf("expected an operator after %s, found %s", c.subject, c.op)
+	}
+	if len(tokens) < 3 {
+		return nil, fmt.Errorf("missing value after %s", c.op)
+	}
+	if len(tokens) > 3 {
+		return nil, fmt.Errorf("unexpected %s after %s %s %s", tokens[3].text, c.subject, c.op, tokens[2].text)
+	}
+
+	value := tokens[2]
+	if c.op == "=~" {
+		var err error
+		if value.kind == tokenRegexp {
+			c.re, err = parseRegexp(value.text)
+		} else {
+			c.re, err = regexp.Compile(value.text)
+		}
+		return c, err
+	}
+	if value.kind != tokenWord && value.kind != tokenString {
+		return nil, fmt.Errorf("expected a value after %s, found %s", c.op, value.text)
+	}
+	c.value = literal(value.text, value.kind == tokenString)
+
+	return c, nil
+}
+
+// bodyKeywordCondition compiles body contains 'text' and body =~ /regexp/
+func bodyKeywordCondition(tokens []token) (Filter, error) {
+	op := tokens[1]
+	isContains := op.kind == tokenWord && strings.EqualFold(op.text, "contains")
+	if !isContains && !(op.kind == tokenOperator && op.text == "=~") {
+		return nil, fmt.Errorf("expected contains or =~ after body, found %s", op.text)
+	}
+	if len(tokens) < 3 {
+		return nil, fmt.Errorf("missing value after body %s", op.text)
+	}
+	if len(tokens) > 3 {
+		return nil, fmt.Errorf("unexpected %s after body %s %s, quote text with spaces", tokens[3].text, op.text, tokens[2].text)
+	}
+
+	value := tokens[2]
+	isText := value.kind == tokenString || value.kind == tokenWord
+	if isContains && isText {
+		return bodyCondition{substringMatcher(value.text)}, nil
+	}
+	if isContains || !isText && value.kind != tokenRegexp {
+		return nil, fmt.Errorf("expected a value after body %s, found %s", op.text, value.text)
+	}
+
+	var re *regexp.Regexp
+	var err error
+	if value.kind == tokenRegexp {
+		re, err = parseRegexp(value.text)
+	} else {
+		re, err = regexp.Compile(value.text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bodyCondition{regexpMatcher{re}}, nil
+}
+
+func isOperator(op string) bool {
+	for _, o := range operators {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+func isJobField(name string) bool {
+	for _, f := range JobFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// literal returns quoted text as string, unquoted numbers and durations as seconds
+func literal(s string, quoted bool) interface{} {
+	if quoted {
+		return s
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	if strings.HasSuffix(s, "d") {
+		if f, err := strconv.ParseFloat(strings.TrimSuffix(s, "d"), 64); err == nil {
+			return f * 86400
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return d.Seconds()
+	}
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	return s
+}
+
+type andFilter struct{ left, right Filter }
+
+func (f andFilter) Match(fields map[string]interface{}, body []byte) bool {
+	return f.left.Match(fields, body) && f.right.Match(fields, body)
+}
+
+type orFilter struct{ left, right Filter }
+
+func (f orFilter) Match(fields map[string]interface{}, body []byte) bool {
+	return f.left.Match(fields, body) || f.right.Match(fields, body)
+}
+
+type notFilter struct{ f Filter }
+
+func (f notFilter) Match(fields map[string]interface{}, body []byte) bool {
+	return !f.f.Match(fields, body)
+}
+
+// bodyCondition matches the body with a search expression
+type bodyCondition struct {
+	m Matcher
+}
+
+func (c bodyCondition) Match(fields map[string]interface{}, body []byte) bool {
+	return c.m.Match(body)
+}
+
+// condition compares a job stat
+type condition struct {
+	subject string
+	op      string
+	value   interface{}
+	re      *regexp.Regexp
+}
+
+// fieldValue turns durations into seconds, numbers into float64 and string types into string
+func fieldValue(v interface{}) interface{} {
+	if d, ok := v.(time.Duration); ok {
+		return d.Seconds()
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	}
+	return v
+}
+
+func (c *condition) Match(fields map[string]interface{}, body []byte) bool {
+	v, ok := fields[c.subject]
+	if !ok {
+		return false
+	}
+	v = fieldValue(v)
+
+	switch c.op {
+	case "=~":
+		return c.re.MatchString(text(v))
+	case "==":
+		return equal(v, c.value)
+	case "!=":
+		return !equal(v, c.value)
+	}
+
+	n, ok := compare(v, c.value)
+	if !ok {
+		return false
+	}
+	switch c.op {
+	case "<":
+		return n < 0
+	case "<=":
+		return n <= 0
+	case ">":
+		return n > 0
+	case ">=":
+		return n >= 0
+	}
+	return false
+}
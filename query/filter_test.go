@@ -0,0 +1,86 @@
+package query
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	fields := map[string]interface{}{
+		"id":       uint64(12),
+		"tube":     "emails",
+		"state":    "buried",
+		"pri":      uint32(1024),
+		"age":      36 * time.Hour,
+		"releases": uint32(5),
+	}
+	body := []byte(`{"version":2,"user":{"id":42},"note":"legacy import (v1)"}`)
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"age > 1d", true},
+		{"age > 2d", false},
+		{"age >= 36h and releases >= 5", true},
+		{"age > 1d and releases > 5", false},
+		{"releases > 5 or state == buried", true},
+		{"not state == buried", false},
+		{"state = 'buried'", true},
+		{"tube =~ /^E/i", true},
+		{"tube =~ ^sms", false},
+		{"legacy", true},
+		{"'legacy import'", true},
+		{"legacy import", true},
+		{"'and or'", false},
+		{"/import \\(v\\d\\)/", true},
+		{".version < 3", true},
+		{".user.id == 42 and not .deleted", true},
+		{"'legacy' and (.version > 2 or pri == 1024)", true},
+		{"(legacy or missing) and age < 1h", false},
+		{".note =~ /LEGACY/i", true},
+		{"body contains 'legacy'", true},
+		{"body contains 'legacy import'", true},
+		{"body contains legacy and age > 86400", true},
+		{"body contains 'modern'", false},
+		{"body =~ /IMPORT \\(V1\\)/i", true},
+		{"body =~ '\"version\":[3-9]'", false},
+		{"not body contains 'legacy' or releases >= 5", true},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(fields, body); got != tt.want {
+			t.Errorf("ParseFilter(%q).Match = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"agee > 1d",
+		"age >",
+		"age > 1d 2d",
+		"age ~ 1d",
+		"age > 1d and",
+		"(age > 1d",
+		"age > 1d)",
+		"not",
+		".version ==",
+		"/unterminated",
+		"'unterminated",
+		"body contains",
+		"body contains legacy import",
+		"body == 'legacy'",
+		"body =~ /(/",
+		"body contains /legacy/",
+	} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("ParseFilter(%q) succeeded, want an error", expr)
+		}
+	}
+}
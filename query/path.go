@@ -0,0 +1,264 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// operators are tried in order, so the two characters ones come first
+var operators = []string{"==", "!=", "<=", ">=", "=~", "<", ">"}
+
+// segment is a single step of a path, either an object key or an array index
+type segment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+type pathMatcher struct {
+	path  []segment
+	op    string
+	value interface{}
+	re    *regexp.Regexp
+}
+
+func parsePathExpr(expr string) (Matcher, error) {
+	path, rest, err := parsePath(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &pathMatcher{path: path}
+	rest = strings.TrimSpace(rest)
+	if rest == "" {
+		return m, nil
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(rest, op) {
+			m.op = op
+			break
+		}
+	}
+	if m.op == "" {
+		return nil, fmt.Errorf("expected one of %s after the path, found %q", strings.Join(operators, " "), rest)
+	}
+
+	value := strings.TrimSpace(rest[len(m.op):])
+	if value == "" {
+		return nil, fmt.Errorf("missing value after %s", m.op)
+	}
+
+	if m.op == "=~" {
+		if strings.HasPrefix(value, "/") {
+			m.re, err = parseRegexp(value)
+		} else {
+			m.re, err = regexp.Compile(value)
+		}
+		return m, err
+	}
+
+	// bare words are taken as strings
+	if err := json.Unmarshal([]byte(value), &m.value); err != nil {
+		m.value = value
+	}
+	return m, nil
+}
+
+// parsePath reads the path at the start of the expression and returns the remaining text
+func parsePath(s string) ([]segment, string, error) {
+	path := []segment{}
+	i := 0
+	for i < len(s) {
+		switch s[i] {
+		case '.':
+			i++
+			start := i
+			for i < len(s) && !strings.ContainsRune(".[ =!<>~", rune(s[i])) {
+				i++
+			}
+			if i > start {
+				path = append(path, segment{key: s[start:i]})
+			}
+
+		case '[':
+			j := skipSpaces(s, i+1)
+			if j < len(s) && s[j] == '"' {
+				end := closingQuote(s, j)
+				if end < 0 {
+					return nil, "", fmt.Errorf("unterminated quoted key in path")
+				}
+				var key string
+				if err := json.Unmarshal([]byte(s[j:end+1]), &key); err != nil {
+					return nil, "", fmt.Errorf("invalid quoted key %s in path", s[j:end+1])
+				}
+				k := skipSpaces(s, end+1)
+				if k >= len(s) || s[k] != ']' {
+					return nil, "", fmt.Errorf("expected ] after key %s in path", s[j:end+1])
+				}
+				path = append(path, segment{key: key})
+				i = k + 1
+				continue
+			}
+
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 {
+				return nil, "", fmt.Errorf("unterminated [ in path")
+			}
+			inner := strings.TrimSpace(s[i+1 : i+end])
+			n, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid index [%s] in path", inner)
+			}
+			path = append(path, segment{index: n, isIndex: true})
+			i += end + 1
+
+		default:
+			return path, s[i:], nil
+		}
+	}
+	return path, "", nil
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && s[i] == ' ' {
+		i++
+	}
+	return i
+}
+
+// closingQuote returns the position of the quote ending the string opened at start, -1 when unterminated
+func closingQuote(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// lookup returns the value at the path, negative indexes count from the end of arrays
+func lookup(v interface{}, path []segment) (interface{}, bool) {
+	for _, seg := range path {
+		if seg.isIndex {
+			a, ok := v.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			i := seg.index
+			if i < 0 {
+				i += len(a)
+			}
+			if i < 0 || i >= len(a) {
+				return nil, false
+			}
+			v = a[i]
+			continue
+		}
+
+		o, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = o[seg.key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func (m *pathMatcher) Match(body []byte) bool {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return false
+	}
+	v, ok := lookup(doc, m.path)
+	if !ok {
+		return false
+	}
+
+	switch m.op {
+	case "":
+		return v != nil
+	case "==":
+		return equal(v, m.value)
+	case "!=":
+		return !equal(v, m.value)
+	case "=~":
+		return m.re.MatchString(text(v))
+	}
+
+	c, ok := compare(v, m.value)
+	if !ok {
+		return false
+	}
+	switch m.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// text returns strings as they are and other values as JSON
+func text(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// equal compares JSON values, numbers kept as strings equal their numeric value, e.g. "42" == 42
+func equal(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	c, ok := compare(a, b)
+	return ok && c == 0
+}
+
+// compare orders two numbers or two strings, numeric strings are compared as numbers with numbers
+func compare(a, b interface{}) (int, bool) {
+	af, aNum := number(a)
+	bf, bNum := number(b)
+	if aNum && bNum {
+		switch {
+		case af < bf:
+			return -1, true
+		case af > bf:
+			return 1, true
+		}
+		return 0, true
+	}
+
+	as, aStr := a.(string)
+	bs, bStr := b.(string)
+	if aStr && bStr {
+		return strings.Compare(as, bs), true
+	}
+	return 0, false
+}
+
+func number(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+// Package query matches job bodies against search expressions.
+//
+// An expression is one of
+//
+//	text                 the body contains the text
+//	/regexp/             the body matches the regular expression, /regexp/i ignores case
+//	.path                the JSON body has a non null value at the path
+//	.path op value       the value at the path compares to the JSON value, op is one of == != < <= > >= =~
+//
+// Paths are made of .name, ["name"] and [index] segments, e.g. .order.items[0].sku
+//
+// Filters combine search expressions with conditions on job stats, see ParseFilter.
+package query
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Matcher reports whether a job body matches the expression
+type Matcher interface {
+	Match(body []byte) bool
+}
+
+// Parse compiles the expression
+func Parse(expr string) (Matcher, error) {
+	expr = strings.TrimSpace(expr)
+	switch {
+	case expr == "":
+		return nil, fmt.Errorf("empty expression")
+
+	case strings.HasPrefix(expr, "/"):
+		re, err := parseRegexp(expr)
+		if err != nil {
+			return nil, err
+		}
+		return regexpMatcher{re}, nil
+
+	case strings.HasPrefix(expr, ".") || strings.HasPrefix(expr, "["):
+		return parsePathExpr(expr)
+	}
+
+	return substringMatcher(expr), nil
+}
+
+type substringMatcher string
+
+func (m substringMatcher) Match(body []byte) bool {
+	return bytes.Contains(body, []byte(m))
+}
+
+type regexpMatcher struct {
+	re *regexp.Regexp
+}
+
+func (m regexpMatcher) Match(body []byte) bool {
+	return m.re.Match(body)
+}
+
+// parseRegexp compiles /expr/ with the optional i flag
+func parseRegexp(s string) (*regexp.Regexp, error) {
+	end := strings.LastIndex(s, "/")
+	if end < 1 {
+		return nil, fmt.Errorf("unterminated regular expression %s", s)
+	}
+
+	pattern := s[1:end]
+	switch flags := s[end+1:]; flags {
+	case "":
+	case "i":
+		pattern = "(?i)" + pattern
+	default:
+		return nil, fmt.Errorf("unknown regular expression flags %q", flags)
+	}
+
+	return regexp.Compile(pattern)
+}
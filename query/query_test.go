@@ -0,0 +1,69 @@
+package query
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	order := []byte(`{"id":"42","total":19.5,"status":"paid","items":[{"sku":"A-1"},{"sku":"B-2"}],"note":null,"odd key":true}`)
+
+	tests := []struct {
+		expr string
+		body []byte
+		want bool
+	}{
+		{"paid", order, true},
+		{"refunded", order, false},
+		{"  paid  ", order, true},
+		{"/st.tus/", order, true},
+		{"/PAID/", order, false},
+		{"/PAID/i", order, true},
+		{".status", order, true},
+		{".note", order, false},
+		{".missing", order, false},
+		{".status == paid", order, true},
+		{`.status == "paid"`, order, true},
+		{".status != paid", order, false},
+		{".id == 42", order, true},
+		{".total > 19", order, true},
+		{".total <= 19", order, false},
+		{".total >= 19.5", order, true},
+		{".status < q", order, true},
+		{".items[0].sku == A-1", order, true},
+		{".items[-1].sku == B-2", order, true},
+		{".items[2].sku", order, false},
+		{`["odd key"] == true`, order, true},
+		{".items[1].sku =~ ^B", order, true},
+		{".status =~ /PAID/i", order, true},
+		{".status == paid", []byte("status paid"), false},
+	}
+	for _, tt := range tests {
+		m, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := m.Match(tt.body); got != tt.want {
+			t.Errorf("Parse(%q).Match(%s) = %v, want %v", tt.expr, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"  ",
+		"/unterminated",
+		"/a/x",
+		"/(/",
+		".status ~ paid",
+		".status ==",
+		".items[x]",
+		".items[0",
+		`["key`,
+		`["key" .x`,
+		".status =~ (",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+const (
+	replayHostInfo = "replay of %s"
+	replayInfo     = "%s %d/%d %s"
+	frozenInfo     = "FROZEN %s %d/%d"
+	seekPrompt     = "Seek to (15:04:05, 2006-01-02T15:04:05Z07:00, +10m or -10m): "
+
+	// replayTick is the period the replay clock advances with while playing
+	replayTick = 100 * time.Millisecond
+	// historyWindow is how long the polled samples are kept in memory
+	historyWindow = time.Hour
+)
+
+// replaySpeeds are the playing speeds cycled through, as multiples of the recorded pace
+var replaySpeeds = []int{1, 10, 60, 600}
+
+// timeline is a sequence of samples with a cursor, either replayed from a recording or kept from the polls
+type timeline struct {
+	samples []*walker.Sample
+	pos     int
+	playing bool
+	speed   int
+	// elapsed is the replay time spent on the current sample
+	elapsed time.Duration
+	// live timelines follow the polled samples unless frozen
+	live   bool
+	frozen bool
+	sync.Mutex
+}
+
+// loadTimeline reads the samples recorded by -record
+func loadTimeline(path string) (*timeline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	samples, err := walker.ReadSamples(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: sample %d: %v", path, len(samples)+1, err)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%s: no samples recorded", path)
+	}
+	return &timeline{samples: samples}, nil
+}
+
+// add appends the polled sample and drops the ones older than the window
+// The cursor follows the last sample unless frozen
+func (t *timeline) add(sample *walker.Sample, window time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.samples = append(t.samples, sample)
+	drop := 0
+	for drop < len(t.samples)-1 && sample.Time.Sub(t.samples[drop].Time) > window {
+		drop++
+	}
+	t.samples = t.samples[drop:]
+
+	if t.frozen {
+		t.moveTo(t.pos - drop)
+	} else {
+		t.moveTo(len(t.samples) - 1)
+	}
+}
+
+// freeze stops the cursor from following the polled samples, unfreezing moves it back to the last one
+func (t *timeline) freeze(v bool) {
+	t.Lock()
+	t.frozen = v
+	if !v {
+		t.moveTo(len(t.samples) - 1)
+	}
+	t.Unlock()
+}
+
+func (t *timeline) isFrozen() bool {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.frozen
+}
+
+// current returns the sample at the cursor along with the one before, nil before the first poll
+func (t *timeline) current() (*walker.Sample, *walker.Sample) {
+	t.Lock()
+	defer t.Unlock()
+
+	if len(t.samples) == 0 {
+		return nil, nil
+	}
+	if t.pos > 0 {
+		return t.samples[t.pos], t.samples[t.pos-1]
+	}
+	return t.samples[t.pos], nil
+}
+
+// moveTo sets the cursor within the samples
+func (t *timeline) moveTo(pos int) {
+	if pos < 0 {
+		pos = 0
+	}
+	if pos >= len(t.samples) {
+		pos = len(t.samples) - 1
+	}
+	t.pos = pos
+	t.elapsed = 0
+}
+
+func (t *timeline) step(n int) {
+	t.Lock()
+	t.moveTo(t.pos + n)
+	t.Unlock()
+}
+
+// seek moves the cursor to the last sample taken at or before the time, the first one when none is
+func (t *timeline) seek(at time.Time) {
+	t.Lock()
+	defer t.Unlock()
+
+	pos := 0
+	for i, s := range t.samples {
+		if s.Time.After(at) {
+			break
+		}
+		pos = i
+	}
+	t.moveTo(pos)
+}
+
+// advance moves the clock by the tick while playing and reports whether the cursor moved
+func (t *timeline) advance(tick time.Duration) bool {
+	t.Lock()
+	defer t.Unlock()
+
+	if !t.playing {
+		return false
+	}
+	t.elapsed += tick * time.Duration(replaySpeeds[t.speed])
+
+	moved := false
+	for t.pos+1 < len(t.samples) {
+		gap := t.samples[t.pos+1].Time.Sub(t.samples[t.pos].Time)
+		if t.elapsed < gap {
+			break
+		}
+		t.elapsed -= gap
+		t.pos++
+		moved = true
+	}
+	// stop at the end of the recording
+	if t.pos == len(t.samples)-1 {
+		t.playing = false
+	}
+	return moved
+}
+
+func (t *timeline) info() string {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.live {
+		if !t.frozen || len(t.samples) == 0 {
+			return ""
+		}
+		return fmt.Sprintf(frozenInfo, t.samples[t.pos].Time.Format(time.RFC3339), t.pos+1, len(t.samples))
+	}
+
+	state := "paused"
+	if t.playing {
+		state = fmt.Sprintf("playing %dx", replaySpeeds[t.speed])
+	}
+	return fmt.Sprintf(replayInfo, t.samples[t.pos].Time.Format(time.RFC3339), t.pos+1, len(t.samples), state)
+}
+
+// playReplay shows the samples at their recorded pace while playing
+func (m *mainFrame) playReplay() {
+	m.showSample()
+
+	go func() {
+		defer close(m.statEvt)
+
+		for {
+			<-time.After(replayTick)
+			// the frame is refreshed only when the cursor moved, a paused replay stays idle
+			if m.replay.advance(replayTick) {
+				m.showSample()
+				m.statEvt <- struct{}{}
+			}
+		}
+	}()
+}
+
+// timeline returns the replayed samples, or the history of the polled ones
+func (m *mainFrame) timeline() *timeline {
+	if m.replay != nil {
+		return m.replay
+	}
+	return m.history
+}
+
+// showSample shows the sample at the cursor of the timeline
+func (m *mainFrame) showSample() {
+	if sample, prev := m.timeline().current(); sample != nil {
+		m.applySample(sample, prev)
+	}
+}
+
+// moveTimeline applies the cursor move and shows the sample, the history is frozen first
+func (m *mainFrame) moveTimeline(move func(t *timeline)) error {
+	t := m.timeline()
+	if t == nil {
+		return nil
+	}
+	if t.live {
+		t.freeze(true)
+	}
+	move(t)
+	m.showSample()
+	m.refresh()
+	return nil
+}
+
+// toggleFreeze stops showing the polled samples, or shows the last one again
+func (m *mainFrame) toggleFreeze() error {
+	if m.history == nil {
+		return nil
+	}
+	m.history.freeze(!m.history.isFrozen())
+	m.showSample()
+	m.refresh()
+	return nil
+}
+
+func (m *mainFrame) togglePlay() error {
+	return m.moveTimeline(func(t *timeline) {
+		t.Lock()
+		if t.pos == len(t.samples)-1 {
+			t.moveTo(0)
+		}
+		t.playing = !t.playing
+		t.Unlock()
+	})
+}
+
+func (m *mainFrame) cycleSpeed() error {
+	return m.moveTimeline(func(t *timeline) {
+		t.Lock()
+		t.speed = (t.speed + 1) % len(replaySpeeds)
+		t.Unlock()
+	})
+}
+
+func (m *mainFrame) previousSample() error {
+	return m.moveTimeline(func(t *timeline) { t.step(-1) })
+}
+
+func (m *mainFrame) nextSample() error {
+	return m.moveTimeline(func(t *timeline) { t.step(1) })
+}
+
+func (m *mainFrame) firstSample() error {
+	return m.moveTimeline(func(t *timeline) { t.step(-len(t.samples)) })
+}
+
+func (m *mainFrame) lastSample() error {
+	return m.moveTimeline(func(t *timeline) { t.step(len(t.samples)) })
+}
+
+// promptSeek asks for a time of the day of the current sample, a full time or an offset from the current sample
+func (m *mainFrame) promptSeek() error {
+	m.ask(seekPrompt, func(text string) error {
+		cur, _ := m.timeline().current()
+		at, err := parseSeek(strings.TrimSpace(text), cur.Time)
+		if err != nil {
+			return err
+		}
+		return m.moveTimeline(func(t *timeline) { t.seek(at) })
+	})
+	return nil
+}
+
+func parseSeek(text string, from time.Time) (time.Time, error) {
+	if strings.HasPrefix(text, "+") || strings.HasPrefix(text, "-") {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return from, err
+		}
+		return from.Add(d), nil
+	}
+	if at, err := time.Parse(time.RFC3339, text); err == nil {
+		return at, nil
+	}
+	clock, err := time.ParseInLocation("15:04:05", text, from.Location())
+	if err != nil {
+		return from, fmt.Errorf("invalid time %q, expected 15:04:05, RFC 3339 or an offset like -10m", text)
+	}
+	y, mo, d := from.Date()
+	return time.Date(y, mo, d, clock.Hour(), clock.Minute(), clock.Second(), 0, from.Location()), nil
+}
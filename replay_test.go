@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+func TestTimelineAdvance(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	tl := &timeline{samples: []*walker.Sample{
+		{Time: start},
+		{Time: start.Add(time.Second)},
+		{Time: start.Add(2 * time.Second)},
+	}}
+
+	// a paused replay never reports a move, the frame isn't refreshed
+	for i := 0; i < 20; i++ {
+		if tl.advance(replayTick) {
+			t.Fatal("the paused replay moved")
+		}
+	}
+
+	tl.playing = true
+	moves := 0
+	for i := 0; i < 30; i++ {
+		if tl.advance(replayTick) {
+			moves++
+		}
+	}
+	if moves != 2 || tl.pos != 2 {
+		t.Errorf("moves = %d, cursor at %d, want 2 moves to the last sample", moves, tl.pos)
+	}
+	if tl.playing {
+		t.Error("the replay is still playing at the end of the recording")
+	}
+}
+
+func TestTimelineFreeze(t *testing.T) {
+	start := time.Unix(1500000000, 0)
+	sampleAt := func(minutes int) *walker.Sample {
+		return &walker.Sample{Time: start.Add(time.Duration(minutes) * time.Minute)}
+	}
+
+	tl := &timeline{live: true}
+	for i := 0; i <= 30; i++ {
+		tl.add(sampleAt(i), historyWindow)
+	}
+	tl.freeze(true)
+	tl.step(-10)
+	chosen, _ := tl.current()
+	if !chosen.Time.Equal(start.Add(20 * time.Minute)) {
+		t.Fatalf("cursor at %s, want 20 minutes after the start", chosen.Time)
+	}
+
+	// the polls past the window drop the older samples, the cursor stays on the chosen one
+	for i := 31; i <= 75; i++ {
+		tl.add(sampleAt(i), historyWindow)
+		if sample, _ := tl.current(); sample != chosen {
+			t.Fatalf("after the poll at %d minutes the cursor moved to %s", i, sample.Time)
+		}
+	}
+	if first := tl.samples[0].Time; !first.Equal(start.Add(15 * time.Minute)) {
+		t.Errorf("first sample at %s, want the ones older than the window dropped", first)
+	}
+
+	// once the chosen sample is dropped the cursor stays on the oldest one
+	for i := 76; i <= 90; i++ {
+		tl.add(sampleAt(i), historyWindow)
+	}
+	if sample, _ := tl.current(); sample != tl.samples[0] {
+		t.Errorf("cursor at %s, want the oldest sample %s", sample.Time, tl.samples[0].Time)
+	}
+
+	tl.freeze(false)
+	if sample, _ := tl.current(); sample != tl.samples[len(tl.samples)-1] {
+		t.Errorf("unfrozen cursor at %s, want the last sample", sample.Time)
+	}
+}
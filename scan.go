@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+const (
+	scanProgressStatus  = "%s: job id %d of %d-%d (%d%%), ESC to cancel"
+	scanCancelledStatus = "%s: cancelled at job id %d"
+
+	// scanProgressInterval is the minimum delay between two progress updates of a running scan
+	scanProgressInterval = 200 * time.Millisecond
+)
+
+// errScanCancelled ends the scans cancelled with ESC
+var errScanCancelled = errors.New("scan cancelled")
+
+// jobScan is a scan running in the background on its own connection, so the event loop and the stats polling
+// go on meanwhile
+type jobScan struct {
+	label     string
+	ids       walker.ScanRange
+	stop      chan struct{}
+	cancelled bool
+	// last probed id, only read and written by the scan goroutine until the scan ends
+	last uint64
+}
+
+func (s *jobScan) progress(id uint64) string {
+	percent := uint64(100)
+	if n := s.ids.To - s.ids.From + 1; s.ids.To >= s.ids.From {
+		percent = (id - s.ids.From) * 100 / n
+	}
+	return fmt.Sprintf(scanProgressStatus, s.label, id, s.ids.From, s.ids.To, percent)
+}
+
+func (s *jobScan) cancel() {
+	if !s.cancelled {
+		s.cancelled = true
+		close(s.stop)
+	}
+}
+
+// post runs the function on the event loop, the frame is refreshed afterwards
+func (m *mainFrame) post(fn func()) {
+	m.tasks <- fn
+}
+
+// startScan runs the scan of the range in the background on a new connection
+// The progress is shown on the status line and ESC cancels the scan, done is called on the event loop once
+// the scan succeeded
+func (m *mainFrame) startScan(label string, ids walker.ScanRange, run func(c *walker.Client) error, done func() error) error {
+	if m.scan != nil {
+		return fmt.Errorf("%s: still running, ESC to cancel", m.scan.label)
+	}
+	c, err := m.createConnection()
+	if err != nil {
+		return err
+	}
+
+	s := &jobScan{label: label, ids: ids, stop: make(chan struct{}), last: ids.From}
+	var shown time.Time
+	c.ScanProgress = func(id uint64) error {
+		s.last = id
+		if time.Since(shown) >= scanProgressInterval {
+			shown = time.Now()
+			status := s.progress(id)
+			m.post(func() { m.showStatus(status) })
+		}
+		select {
+		case <-s.stop:
+			return errScanCancelled
+		default:
+		}
+		return nil
+	}
+
+	m.scan = s
+	go func() {
+		err := run(c)
+		c.Close()
+		m.post(func() {
+			m.scan = nil
+			switch {
+			case err == errScanCancelled:
+				m.showStatus(fmt.Sprintf(scanCancelledStatus, s.label, s.last))
+			case err != nil:
+				m.showStatus(err.Error())
+			default:
+				if err := done(); err != nil {
+					m.showStatus(err.Error())
+				}
+			}
+		})
+	}()
+
+	return nil
+}
+
+// cancelScan stops the running scan, it reports whether there was one
+func (m *mainFrame) cancelScan() bool {
+	if m.scan == nil {
+		return false
+	}
+	m.scan.cancel()
+	return true
+}
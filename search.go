@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kadekcipta/beanwalker/query"
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+const (
+	searchTitle    = "[ Search %s in %s: %d jobs ]"
+	searchLabel    = "Search %s in %s"
+	searchPrompt   = "Search (text, /regexp/, .path == value): "
+	jobTitle       = "[ Job: %d ]"
+	searchedStatus = "%d jobs matched, job ids %d-%d scanned"
+
+	// scanIDs bounds the job ids probed by searches and bulk operations, the last ones created are scanned
+	scanIDs = 100000
+	// searchMaxResults bounds the number of listed jobs
+	searchMaxResults = 1000
+)
+
+func (m *mainFrame) promptSearch() error {
+	tubeName := m.currentTubeName()
+	if tubeName == "" {
+		return nil
+	}
+	m.ask(searchPrompt, func(expr string) error {
+		return m.searchJobs(tubeName, expr)
+	})
+	return nil
+}
+
+// searchJobs lists the ready, delayed and buried jobs of the tube whose body matches the expression
+// Bodies are matched both as they are and decoded, so JSON paths apply to compressed or MessagePack bodies too
+// The jobs are scanned in the background, the results are listed once the scan is over
+func (m *mainFrame) searchJobs(tubeName, expr string) error {
+	q, err := query.Parse(expr)
+	if err != nil {
+		return err
+	}
+	ids, err := m.client.LastIDs(scanIDs)
+	if err != nil {
+		return err
+	}
+
+	m.statsLock.RLock()
+	f := m.formatter
+	m.statsLock.RUnlock()
+	columns := m.searchGrid.Columns
+
+	states := []walker.State{walker.StateReady, walker.StateDelayed, walker.StateBuried}
+	rows := [][]string{}
+	scan := func(c *walker.Client) error {
+		return c.Scan(tubeName, states, ids, func(job *walker.Job, stats *walker.JobStats) error {
+			body := m.config.decodedBody(tubeName, job.Body)
+			if !q.Match(body) && !q.Match(job.Body) {
+				return nil
+			}
+
+			row := f.Row(columns, stats)
+			row[len(row)-1] = strings.Join(strings.Fields(string(body)), " ")
+			rows = append(rows, row)
+			if len(rows) >= searchMaxResults {
+				return walker.ErrStopScan
+			}
+			return nil
+		})
+	}
+
+	return m.startScan(fmt.Sprintf(searchLabel, expr, tubeName), ids, scan, func() error {
+		m.searchTube = tubeName
+		m.searchRows = rows
+		m.searchGrid.SetTitle(fmt.Sprintf(searchTitle, expr, tubeName, len(rows)))
+		m.searchGrid.reset()
+		m.searchGrid.UpdateData(rows)
+		m.showView(m.searchGrid)
+		m.showStatus(fmt.Sprintf(searchedStatus, len(rows), ids.From, ids.To))
+		return nil
+	})
+}
+
+// selectedJobID returns the id of the job selected in the search results
+func (m *mainFrame) selectedJobID() (uint64, bool) {
+	row := m.searchGrid.CurrentRow()
+	if row == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimSpace(row[0]), 10, 64)
+	return id, err == nil
+}
+
+func (m *mainFrame) inspectSearchResult() error {
+	id, ok := m.selectedJobID()
+	if !ok {
+		return nil
+	}
+	job, err := m.client.PeekJob(id)
+	if err != nil {
+		return err
+	}
+
+	rows := []KeyValueRow{{Key: fmt.Sprintf("job #%d", id)}}
+	rows = append(rows, m.inspectJob(job, m.config.decoder(m.searchTube))...)
+	m.openInspector(fmt.Sprintf(jobTitle, id), rows, m.searchGrid)
+
+	return nil
+}
+
+// removeSearchResult drops the job from the search results
+func (m *mainFrame) removeSearchResult(id uint64) {
+	rows := [][]string{}
+	for _, row := range m.searchRows {
+		if strings.TrimSpace(row[0]) != strconv.FormatUint(id, 10) {
+			rows = append(rows, row)
+		}
+	}
+	m.searchRows = rows
+	m.searchGrid.UpdateData(rows)
+}
+
+func (m *mainFrame) deleteSearchResult() error {
+	id, ok := m.selectedJobID()
+	if !ok {
+		return nil
+	}
+	if err := m.client.DeleteJob(id); err != nil && !walker.IsNotFound(err) {
+		return err
+	}
+	m.removeSearchResult(id)
+	m.showStatus(fmt.Sprintf("%s: job #%d deleted", m.searchTube, id))
+
+	return nil
+}
+
+// deleteSearchResults deletes every job of the search results
+func (m *mainFrame) deleteSearchResults() error {
+	count := 0
+	for i, row := range m.searchRows {
+		id, err := strconv.ParseUint(strings.TrimSpace(row[0]), 10, 64)
+		if err != nil {
+			continue
+		}
+		// already gone jobs are fine, the remaining ones are kept listed on failure
+		if err := m.client.DeleteJob(id); err != nil && !walker.IsNotFound(err) {
+			m.searchRows = m.searchRows[i:]
+			m.searchGrid.UpdateData(m.searchRows)
+			return err
+		}
+		count++
+	}
+	m.searchRows = [][]string{}
+	m.searchGrid.UpdateData(m.searchRows)
+	m.showStatus(fmt.Sprintf("%s: %d jobs deleted", m.searchTube, count))
+
+	return nil
+}
+
+func (m *mainFrame) closeSearch() error {
+	m.searchRows = nil
+	m.showView(m.tubesStatsGrid)
+	m.refresh()
+
+	return nil
+}
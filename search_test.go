@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nsf/termbox-go"
+)
+
+// finishScan runs the functions posted by the scan on the event loop until it is over
+func finishScan(t *testing.T, m *mainFrame) {
+	t.Helper()
+
+	for m.scan != nil {
+		select {
+		case fn := <-m.tasks:
+			fn()
+		case <-time.After(5 * time.Second):
+			t.Fatal("the scan didn't end")
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s, m := newTestFrame(t, 100, 24)
+	s.Put("emails", []byte(`{"user_id":7}`), 10, time.Hour, time.Minute)
+	s.Put("emails", []byte("plain"), 10, 0, time.Minute)
+
+	if err := m.searchJobs("emails", ".user_id > 10"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.searchJobs("emails", "plain"); err == nil {
+		t.Error("a second search started while the first one runs")
+	}
+	finishScan(t, m)
+
+	if !m.searchGrid.Visible() || len(m.searchRows) != 1 {
+		t.Fatalf("search results %v, want the job of user 42", m.searchRows)
+	}
+	if row := m.searchRows[0]; strings.TrimSpace(row[0]) != "1" || row[len(row)-1] != `{ "user_id": 42 }` {
+		t.Errorf("search result = %q", row)
+	}
+	if line := frameBuffer(m).Line(23); !strings.HasPrefix(line, " 1 jobs matched, job ids 1-4 scanned") {
+		t.Errorf("status line = %q", line)
+	}
+}
+
+func TestSearchCancel(t *testing.T) {
+	_, m := newTestFrame(t, 100, 24)
+
+	if err := m.searchJobs("emails", "user_id"); err != nil {
+		t.Fatal(err)
+	}
+	// the scan waits for its first progress update to be shown, it is cancelled meanwhile
+	press(m, termbox.KeyEsc)
+	(<-m.tasks)()
+	if line := frameBuffer(m).Line(23); !strings.HasPrefix(line, " Search user_id in emails: job id 1 of 1-2 (0%), ESC to cancel") {
+		t.Errorf("progress status = %q", line)
+	}
+
+	finishScan(t, m)
+
+	if m.searchGrid.Visible() {
+		t.Error("the cancelled search shows results")
+	}
+	if line := frameBuffer(m).Line(23); !strings.HasPrefix(line, " Search user_id in emails: cancelled at job id 1") {
+		t.Errorf("status line = %q", line)
+	}
+}
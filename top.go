@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/walker"
+	"github.com/mattn/go-runewidth"
+)
+
+// clearScreen moves the cursor home and clears the terminal, the table is drawn again in place
+const clearScreen = "\033[H\033[2J"
+
+// top prints the stats of the grids as plain text, for terminals termbox cannot drive and logs
+type top struct {
+	client    *walker.Client
+	formatter Formatter
+	w         io.Writer
+	// columns of the grids followed by the stats discovered so far
+	sysColumns  []GridColumn
+	tubeColumns []GridColumn
+	// the columns changed since the headers were last printed
+	columnsChanged bool
+}
+
+func newTop(c *walker.Client, formatter Formatter, w io.Writer) *top {
+	return &top{
+		client:         c,
+		formatter:      formatter,
+		w:              w,
+		sysColumns:     copyColumns(sysStatsColumns),
+		tubeColumns:    copyColumns(tubeStatsColumns),
+		columnsChanged: true,
+	}
+}
+
+// isTerminal reports whether the file is a terminal, the tables are refreshed in place only then
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// writeTable writes the header and the rows aligned to the column widths, each line starts with the prefix
+func writeTable(w io.Writer, prefix string, columns []GridColumn, rows [][]string, header bool) {
+	line := func(cells []string) {
+		parts := make([]string, len(columns))
+		for i, col := range columns {
+			cell := ""
+			if i < len(cells) {
+				cell = cells[i]
+			}
+			if col.Align == AlignRight {
+				parts[i] = runewidth.FillLeft(cell, col.Width-1)
+			} else {
+				parts[i] = runewidth.FillRight(cell, col.Width-1)
+			}
+		}
+		fmt.Fprintln(w, prefix+strings.TrimRight(strings.Join(parts, " "), " "))
+	}
+
+	if header {
+		names := []string{}
+		for _, col := range columns {
+			names = append(names, col.Name)
+		}
+		line(names)
+	}
+	for _, row := range rows {
+		line(row)
+	}
+}
+
+// sample returns the rows of the system stats and of the tubes stats
+func (t *top) sample() ([][]string, [][]string, error) {
+	sys, err := t.client.ServerStats()
+	if err != nil {
+		return nil, nil, err
+	}
+	tubes, err := t.client.AllTubeStats()
+	if err != nil {
+		return nil, nil, err
+	}
+	sysRows, tubeRows := t.rows(sys, tubes)
+	return sysRows, tubeRows, nil
+}
+
+// rows formats the stats, the stats unknown to the columns are appended as extra columns as the grids do
+func (t *top) rows(sys *walker.ServerStats, tubes []*walker.TubeStats) ([][]string, [][]string) {
+	t.discover(&t.sysColumns, sys.Raw)
+	for _, stats := range tubes {
+		t.discover(&t.tubeColumns, stats.Raw)
+	}
+
+	tubeRows := [][]string{}
+	for _, stats := range tubes {
+		tubeRows = append(tubeRows, t.formatter.Row(t.tubeColumns, stats))
+	}
+	return [][]string{t.formatter.Row(t.sysColumns, sys)}, tubeRows
+}
+
+func (t *top) discover(columns *[]GridColumn, stats map[string]string) {
+	if cols := unknownColumns(*columns, stats); len(cols) > 0 {
+		*columns = append(*columns, cols...)
+		t.columnsChanged = true
+	}
+}
+
+// printTables draws both tables again in place of the previous ones
+func (t *top) printTables(now time.Time, sysRows, tubeRows [][]string) {
+	fmt.Fprint(t.w, clearScreen)
+	fmt.Fprintf(t.w, "%s %s\n\n", hostInfo, now.Format(time.RFC3339))
+	writeTable(t.w, "", t.sysColumns, sysRows, true)
+	fmt.Fprintln(t.w)
+	writeTable(t.w, "", t.tubeColumns, tubeRows, true)
+}
+
+// printLines appends the timestamped rows, the headers are printed again only when columns were discovered
+func (t *top) printLines(now time.Time, sysRows, tubeRows [][]string) {
+	ts := now.Format(time.RFC3339) + " "
+	if t.columnsChanged {
+		writeTable(t.w, strings.Repeat(" ", len(ts))+"system ", t.sysColumns, nil, true)
+		writeTable(t.w, strings.Repeat(" ", len(ts))+"tube   ", t.tubeColumns, nil, true)
+		t.columnsChanged = false
+	}
+	writeTable(t.w, ts+"system ", t.sysColumns, sysRows, false)
+	writeTable(t.w, ts+"tube   ", t.tubeColumns, tubeRows, false)
+}
+
+func runTop(args []string) error {
+	f := newCommandFlags("top")
+	interval := f.Duration("i", 2*time.Second, "refresh interval")
+	lines := f.Bool("lines", false, "append timestamped lines instead of refreshing the tables, the default when the output isn't a terminal")
+	count := f.Int("n", 0, "number of samples to print, no limit when 0")
+	humanize := f.Bool("humanize", false, "humanized values, e.g. 1.2k, 3h12m and 64KiB")
+	f.Parse(args)
+
+	if *interval <= 0 {
+		return usageError("top: the interval must be positive")
+	}
+
+	c, err := f.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	hostInfo = f.addr()
+	t := newTop(c, Formatter{Humanize: *humanize}, os.Stdout)
+	if !isTerminal(os.Stdout) {
+		*lines = true
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for i := 0; *count <= 0 || i < *count; i++ {
+		if i > 0 {
+			<-ticker.C
+		}
+		sysRows, tubeRows, err := t.sample()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if *lines {
+			t.printLines(now, sysRows, tubeRows)
+		} else {
+			t.printTables(now, sysRows, tubeRows)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/walker"
+)
+
+func TestTopLines(t *testing.T) {
+	var b bytes.Buffer
+	tp := newTop(nil, Formatter{}, &b)
+	now := time.Unix(1500000000, 0).UTC()
+
+	sys := &walker.ServerStats{CurrentJobsReady: 1, Raw: map[string]string{"current-jobs-ready": "1"}}
+	tubes := []*walker.TubeStats{{Name: "emails", CurrentJobsReady: 1, Raw: map[string]string{"name": "emails"}}}
+	sysRows, tubeRows := tp.rows(sys, tubes)
+	tp.printLines(now, sysRows, tubeRows)
+	sysRows, tubeRows = tp.rows(sys, tubes)
+	tp.printLines(now, sysRows, tubeRows)
+
+	out := b.String()
+	if strings.Contains(out, clearScreen) {
+		t.Error("line output clears the screen")
+	}
+	if n := strings.Count(out, "current-jobs-ready"); n != 2 {
+		t.Errorf("current-jobs-ready header printed %d times, want once per table:\n%s", n, out)
+	}
+
+	// a stat of a newer server is appended as a column and the headers are printed again
+	b.Reset()
+	sys.Raw["current-jobs-archived"] = "7"
+	tubes[0].Raw["cmd-archive"] = "3"
+	sysRows, tubeRows = tp.rows(sys, tubes)
+	tp.printLines(now, sysRows, tubeRows)
+
+	lines := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("output has %d lines, want the headers and the rows again:\n%s", len(lines), b.String())
+	}
+	if !strings.HasSuffix(lines[0], "current-jobs-archived") || !strings.HasSuffix(lines[2], " 7") {
+		t.Errorf("system header/row = %q/%q, want the discovered stat last", lines[0], lines[2])
+	}
+	if !strings.HasSuffix(lines[1], "cmd-archive") || !strings.HasSuffix(lines[3], " 3") {
+		t.Errorf("tube header/row = %q/%q, want the discovered stat last", lines[1], lines[3])
+	}
+}
+
+func TestUnknownColumns(t *testing.T) {
+	columns := []GridColumn{{"name", AlignLeft, 10}, {"ready", AlignRight, 8}}
+	got := unknownColumns(columns, map[string]string{"name": "a", "zeta": "1", "alpha": "2"})
+	if len(got) != 2 || got[0].Name != "alpha" || got[1].Name != "zeta" {
+		t.Errorf("unknown columns = %v, want alpha and zeta", got)
+	}
+}
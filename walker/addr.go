@@ -0,0 +1,182 @@
+package walker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kr/beanstalk"
+	"golang.org/x/crypto/ssh"
+)
+
+// DefaultPort is the port beanstalkd listens on by default
+const DefaultPort = 11300
+
+// Addr is the network address of a beanstalkd server
+type Addr struct {
+	Network string
+	Address string
+}
+
+// ParseAddr parses tcp://host:port, unix:///path/to/socket, a host:port or a socket path
+// The default port is used when the port is missing, IPv6 hosts are written in brackets, e.g. tcp://[::1]:11300
+func ParseAddr(s string) (Addr, error) {
+	addr := s
+	switch {
+	case strings.HasPrefix(s, "unix://"):
+		path := strings.TrimPrefix(s, "unix://")
+		if path == "" {
+			return Addr{}, fmt.Errorf("missing socket path in %q", addr)
+		}
+		return Addr{"unix", path}, nil
+
+	case strings.HasPrefix(s, "/"):
+		return Addr{"unix", s}, nil
+
+	case strings.HasPrefix(s, "tcp://"):
+		s = strings.TrimPrefix(s, "tcp://")
+
+	case strings.Contains(s, "://"):
+		return Addr{}, fmt.Errorf("unsupported address %q, expected tcp://host:port or unix:///path", addr)
+	}
+
+	s = strings.TrimSuffix(s, "/")
+	if s == "" {
+		return Addr{}, fmt.Errorf("missing host")
+	}
+	if _, _, err := net.SplitHostPort(s); err != nil {
+		// a host alone, possibly a bracketed IPv6 one
+		s = net.JoinHostPort(unbracket(s), strconv.Itoa(DefaultPort))
+	}
+
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return Addr{}, fmt.Errorf("invalid address %q: %v", addr, err)
+	}
+	if strings.ContainsAny(host, "[]") || strings.Contains(host, ":") && !isIPv6(host) {
+		return Addr{}, fmt.Errorf("invalid host %q in %q, IPv6 hosts are written in brackets, e.g. [::1]:11300", host, addr)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return Addr{}, fmt.Errorf("invalid port %q in %q", port, addr)
+	}
+	return Addr{"tcp", s}, nil
+}
+
+// HostPortAddr returns the address of the host and the port, the host may be a full address already
+func HostPortAddr(host string, port int) string {
+	if strings.Contains(host, "://") || strings.HasPrefix(host, "/") {
+		return host
+	}
+	// host:port or [::1]:11300
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(unbracket(host), strconv.Itoa(port))
+}
+
+// unbracket strips the brackets of an IPv6 host, only when a single pair wraps the whole host
+func unbracket(host string) string {
+	if len(host) >= 2 && host[0] == '[' && host[len(host)-1] == ']' && strings.Count(host, "[") == 1 && strings.Count(host, "]") == 1 {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
+
+// isIPv6 reports whether the host is an IPv6 address, with an optional zone, e.g. fe80::1%eth0
+func isIPv6(host string) bool {
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	return strings.Contains(host, ":") && net.ParseIP(host) != nil
+}
+
+func (a Addr) String() string {
+	return a.Network + "://" + a.Address
+}
+
+// Dialer connects to beanstalkd servers
+type Dialer struct {
+	// Timeout bounds the connection, no timeout when zero
+	Timeout time.Duration
+	// SSH tunnels the connections through the jump host when set
+	SSH *SSHConfig
+	// TLS secures the connections when set
+	TLS *TLSConfig
+
+	tunnel *ssh.Client
+	lock   sync.Mutex
+}
+
+// Dial connects to the beanstalkd at the address, see ParseAddr
+func (d *Dialer) Dial(addr string) (*Client, error) {
+	a, err := ParseAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	dial := func() (io.ReadWriteCloser, error) {
+		return d.dialAddr(a)
+	}
+	rwc, err := dial()
+	if err != nil {
+		return nil, err
+	}
+	c := NewClient(beanstalk.NewConn(rwc))
+	c.dial = dial
+	return c, nil
+}
+
+// dialAddr connects to the address, over TLS when configured
+func (d *Dialer) dialAddr(a Addr) (net.Conn, error) {
+	conn, err := d.dialConn(a)
+	if err != nil || d.TLS == nil {
+		return conn, err
+	}
+	return d.TLS.handshake(conn, a, d.Timeout)
+}
+
+// dialConn connects directly or through the jump host, which is dialed again once its connection is lost
+func (d *Dialer) dialConn(a Addr) (net.Conn, error) {
+	if d.SSH == nil {
+		return net.DialTimeout(a.Network, a.Address, d.Timeout)
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.tunnel != nil {
+		conn, err := d.dialTunnel(d.tunnel, a)
+		if err == nil {
+			return conn, nil
+		}
+		d.tunnel.Close()
+		d.tunnel = nil
+	}
+	tunnel, err := d.SSH.dial(d.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("ssh %s: %v", d.SSH.Target, err)
+	}
+	conn, err := d.dialTunnel(tunnel, a)
+	if err != nil {
+		tunnel.Close()
+		return nil, err
+	}
+	d.tunnel = tunnel
+	return conn, nil
+}
+
+// dialTunnel connects to the address from the jump host within the timeout
+func (d *Dialer) dialTunnel(tunnel *ssh.Client, a Addr) (net.Conn, error) {
+	ctx := context.Background()
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+	return tunnel.DialContext(ctx, a.Network, a.Address)
+}
@@ -0,0 +1,78 @@
+package walker
+
+import "testing"
+
+func TestParseAddr(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Addr
+	}{
+		{"localhost", Addr{"tcp", "localhost:11300"}},
+		{"localhost:11301", Addr{"tcp", "localhost:11301"}},
+		{"10.0.0.5", Addr{"tcp", "10.0.0.5:11300"}},
+		{"::1", Addr{"tcp", "[::1]:11300"}},
+		{"[::1]", Addr{"tcp", "[::1]:11300"}},
+		{"[::1]:11301", Addr{"tcp", "[::1]:11301"}},
+		{"fe80::1%eth0", Addr{"tcp", "[fe80::1%eth0]:11300"}},
+		{"tcp://[2001:db8::1]:11301", Addr{"tcp", "[2001:db8::1]:11301"}},
+		{"tcp://queue.internal", Addr{"tcp", "queue.internal:11300"}},
+		{"tcp://queue.internal:11301/", Addr{"tcp", "queue.internal:11301"}},
+		{"unix:///run/beanstalkd.sock", Addr{"unix", "/run/beanstalkd.sock"}},
+		{"/run/beanstalkd.sock", Addr{"unix", "/run/beanstalkd.sock"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseAddr(tt.in)
+		if err != nil {
+			t.Errorf("ParseAddr(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAddr(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseAddrErrors(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"unix://",
+		"http://localhost",
+		"[::1]:11301]:11300",
+		"[localhost:11301]:11300",
+		"[::1",
+		"::1]",
+		"localhost:port",
+		"localhost:70000",
+		"1::2::3",
+	} {
+		if a, err := ParseAddr(in); err == nil {
+			t.Errorf("ParseAddr(%q) = %v, want an error", in, a)
+		}
+	}
+}
+
+func TestHostPortAddr(t *testing.T) {
+	tests := []struct {
+		host string
+		port int
+		want string
+	}{
+		{"localhost", 11300, "localhost:11300"},
+		{"localhost:11301", 11300, "localhost:11301"},
+		{"::1", 11301, "[::1]:11301"},
+		{"[::1]", 11301, "[::1]:11301"},
+		{"[::1]:11301", 11300, "[::1]:11301"},
+		{"tcp://queue.internal:11301", 11300, "tcp://queue.internal:11301"},
+		{"unix:///run/beanstalkd.sock", 11300, "unix:///run/beanstalkd.sock"},
+		{"/run/beanstalkd.sock", 11300, "/run/beanstalkd.sock"},
+	}
+	for _, tt := range tests {
+		got := HostPortAddr(tt.host, tt.port)
+		if got != tt.want {
+			t.Errorf("HostPortAddr(%q, %d) = %q, want %q", tt.host, tt.port, got, tt.want)
+		}
+		if _, err := ParseAddr(got); err != nil {
+			t.Errorf("ParseAddr(HostPortAddr(%q, %d)): %v", tt.host, tt.port, err)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+// Package walker is the beanstalkd client layer shared by the user interface and the command line tools.
+// It exposes typed stats and bulk job operations on top of github.com/kr/beanstalk.
+package walker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kr/beanstalk"
+)
+
+// State is the state of a job
+type State string
+
+const (
+	StateReady    State = "ready"
+	StateDelayed  State = "delayed"
+	StateReserved State = "reserved"
+	StateBuried   State = "buried"
+)
+
+// ErrDraining is returned by operations putting jobs while the server is in drain mode
+var ErrDraining = errors.New("server is draining and refuses new jobs, put and move are not allowed")
+
+// ErrNoReserveJob is returned when burying a job by id on a server older than beanstalkd 1.12, which has no reserve-job
+var ErrNoReserveJob = errors.New("burying a job by id needs reserve-job, added in beanstalkd 1.12")
+
+// ParseState returns the state of the name, only peekable states are accepted
+func ParseState(name string) (State, error) {
+	switch s := State(name); s {
+	case StateReady, StateDelayed, StateBuried:
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid job state %q, expected ready, delayed or buried", name)
+}
+
+// IsNotFound reports whether the error is the NOT_FOUND reply of the server
+func IsNotFound(err error) bool {
+	cerr, ok := err.(beanstalk.ConnError)
+	return ok && cerr.Err == beanstalk.ErrNotFound
+}
+
+// Job is a job body along with its id
+type Job struct {
+	ID   uint64
+	Body []byte
+}
+
+// Result describes the outcome of a bulk operation
+type Result struct {
+	Operation string
+	Tube      string
+	State     State
+	Count     int
+	IDs       []uint64
+	Duration  time.Duration
+	// DryRun results only count the jobs the operation applies to
+	DryRun bool
+}
+
+func (r *Result) String() string {
+	if r.DryRun {
+		if r.State != "" {
+			return fmt.Sprintf("%s: %d %s jobs would be %s", r.Tube, r.Count, r.State, r.Operation)
+		}
+		return fmt.Sprintf("%s: %d jobs would be %s", r.Tube, r.Count, r.Operation)
+	}
+	if r.Operation == "paused" {
+		return fmt.Sprintf("%s: paused for %s", r.Tube, r.Duration)
+	}
+	if r.State != "" {
+		return fmt.Sprintf("%s: %d %s jobs %s", r.Tube, r.Count, r.State, r.Operation)
+	}
+	return fmt.Sprintf("%s: %d jobs %s", r.Tube, r.Count, r.Operation)
+}
+
+// Client talks to a single beanstalkd server
+type Client struct {
+	Conn *beanstalk.Conn
+
+	// ScanProgress is called before every job id probed by Scan, an error other than ErrStopScan ends the scan
+	ScanProgress func(id uint64) error
+
+	// dial opens another connection to the server, for the commands missing from the beanstalk package
+	dial    func() (io.ReadWriteCloser, error)
+	raw     *textproto.Conn
+	rawLock sync.Mutex
+
+	// drain mode and version as of the last server stats, known once they have been read
+	draining   bool
+	drainKnown bool
+	version    string
+	statsLock  sync.Mutex
+}
+
+// Dial connects to the beanstalkd at the address without timeout, see ParseAddr
+func Dial(addr string) (*Client, error) {
+	return (&Dialer{}).Dial(addr)
+}
+
+// NewClient returns a client using the established connection
+// Commands needing another connection, like KickJob, are not available
+func NewClient(c *beanstalk.Conn) *Client {
+	return &Client{Conn: c}
+}
+
+func (c *Client) Close() error {
+	c.rawLock.Lock()
+	if c.raw != nil {
+		c.raw.Close()
+	}
+	c.rawLock.Unlock()
+
+	return c.Conn.Close()
+}
+
+// rawCommand sends the command line over the dedicated connection and returns the reply line
+func (c *Client) rawCommand(format string, args ...interface{}) (string, error) {
+	c.rawLock.Lock()
+	defer c.rawLock.Unlock()
+
+	if c.raw == nil {
+		if c.dial == nil {
+			return "", errors.New("the client has no dialer for the commands missing from the beanstalk package")
+		}
+		rwc, err := c.dial()
+		if err != nil {
+			return "", err
+		}
+		c.raw = textproto.NewConn(rwc)
+	}
+
+	var line string
+	err := c.raw.PrintfLine(format, args...)
+	if err == nil {
+		line, err = c.raw.ReadLine()
+	}
+	// the body following a RESERVED reply is skipped, none of the raw commands needs it
+	var id, size uint64
+	if n, _ := fmt.Sscanf(line, "RESERVED %d %d", &id, &size); err == nil && n == 2 {
+		_, err = io.CopyN(ioutil.Discard, c.raw.R, int64(size)+2)
+	}
+	if err != nil {
+		// the connection state is unknown, the next command dials again
+		c.raw.Close()
+		c.raw = nil
+		return "", err
+	}
+	return line, nil
+}
+
+// KickJob moves the buried or delayed job of the id into the ready queue
+func (c *Client) KickJob(id uint64) error {
+	reply, err := c.rawCommand("kick-job %d", id)
+	if err != nil {
+		return err
+	}
+
+	switch reply {
+	case "KICKED":
+		return nil
+	case "NOT_FOUND":
+		return beanstalk.ConnError{Conn: c.Conn, Op: "kick-job", Err: beanstalk.ErrNotFound}
+	}
+	return fmt.Errorf("kick-job: unexpected reply %q", reply)
+}
+
+// CheckReserveJob returns ErrNoReserveJob when the server is older than beanstalkd 1.12
+// The version of the last server stats is used, the stats are only read when they never have been
+func (c *Client) CheckReserveJob() error {
+	c.statsLock.Lock()
+	version := c.version
+	c.statsLock.Unlock()
+
+	if version == "" {
+		stats, err := c.ServerStats()
+		if err != nil {
+			return err
+		}
+		version = stats.Version
+	}
+	if !versionAtLeast(version, 1, 12) {
+		return ErrNoReserveJob
+	}
+	return nil
+}
+
+// versionAtLeast reports whether the major.minor beanstalkd version is at least the given one
+// Versions not starting with major.minor, e.g. development builds, are assumed recent
+func versionAtLeast(version string, major, minor int) bool {
+	var v [2]int
+	if n, _ := fmt.Sscanf(version, "%d.%d", &v[0], &v[1]); n < 2 {
+		return true
+	}
+	return v[0] > major || (v[0] == major && v[1] >= minor)
+}
+
+// BuryJob reserves the ready or delayed job of the id and buries it with the priority, see CheckReserveJob
+// The job stays reserved by the dedicated connection until its TTR when burying fails
+func (c *Client) BuryJob(id uint64, pri uint32) error {
+	reply, err := c.rawCommand("reserve-job %d", id)
+	if err != nil {
+		return err
+	}
+	switch {
+	case reply == "NOT_FOUND":
+		return beanstalk.ConnError{Conn: c.Conn, Op: "reserve-job", Err: beanstalk.ErrNotFound}
+	case !strings.HasPrefix(reply, "RESERVED "):
+		return fmt.Errorf("reserve-job: unexpected reply %q", reply)
+	}
+
+	reply, err = c.rawCommand("bury %d %d", id, pri)
+	if err != nil {
+		return err
+	}
+	if reply != "BURIED" {
+		return fmt.Errorf("bury: unexpected reply %q", reply)
+	}
+	return nil
+}
+
+func (c *Client) tube(name string) *beanstalk.Tube {
+	return &beanstalk.Tube{Conn: c.Conn, Name: name}
+}
+
+// ServerStats returns the server wide stats
+func (c *Client) ServerStats() (*ServerStats, error) {
+	raw, err := c.Conn.Stats()
+	if err != nil {
+		return nil, err
+	}
+	stats := &ServerStats{}
+	if err := parseStats(raw, stats); err != nil {
+		return nil, err
+	}
+	c.statsLock.Lock()
+	c.draining, c.drainKnown, c.version = stats.Draining, true, stats.Version
+	c.statsLock.Unlock()
+	return stats, nil
+}
+
+func (c *Client) setDraining(v bool) {
+	c.statsLock.Lock()
+	c.draining, c.drainKnown = v, true
+	c.statsLock.Unlock()
+}
+
+// ListTubes returns the names of existing tubes
+func (c *Client) ListTubes() ([]string, error) {
+	return c.Conn.ListTubes()
+}
+
+// TubeStats returns the stats of the tube
+func (c *Client) TubeStats(name string) (*TubeStats, error) {
+	raw, err := c.tube(name).Stats()
+	if err != nil {
+		return nil, err
+	}
+	stats := &TubeStats{}
+	if err := parseStats(raw, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// AllTubeStats returns the stats of every existing tube
+func (c *Client) AllTubeStats() ([]*TubeStats, error) {
+	tubes, err := c.ListTubes()
+	if err != nil {
+		return nil, err
+	}
+
+	all := []*TubeStats{}
+	for _, name := range tubes {
+		stats, err := c.TubeStats(name)
+		if err != nil {
+			// the tube may be gone in the meantime
+			if IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		all = append(all, stats)
+	}
+	return all, nil
+}
+
+// JobStats returns the stats of the job
+func (c *Client) JobStats(id uint64) (*JobStats, error) {
+	raw, err := c.Conn.StatsJob(id)
+	if err != nil {
+		return nil, err
+	}
+	stats := &JobStats{}
+	if err := parseStats(raw, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Peek returns the next job of the state in the tube
+func (c *Client) Peek(tubeName string, state State) (*Job, error) {
+	var id uint64
+	var body []byte
+	var err error
+
+	t := c.tube(tubeName)
+	switch state {
+	case StateReady:
+		id, body, err = t.PeekReady()
+	case StateDelayed:
+		id, body, err = t.PeekDelayed()
+	case StateBuried:
+		id, body, err = t.PeekBuried()
+	default:
+		return nil, fmt.Errorf("cannot peek %s jobs", state)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Job{id, body}, nil
+}
+
+// PeekJob returns the job of the id
+func (c *Client) PeekJob(id uint64) (*Job, error) {
+	body, err := c.Conn.Peek(id)
+	if err != nil {
+		return nil, err
+	}
+	return &Job{id, body}, nil
+}
+
+// CheckDraining returns ErrDraining when the server was in drain mode as of the last server stats
+// The stats are only read when they never have been, e.g. before the first poll
+func (c *Client) CheckDraining() error {
+	c.statsLock.Lock()
+	draining, known := c.draining, c.drainKnown
+	c.statsLock.Unlock()
+
+	if !known {
+		stats, err := c.ServerStats()
+		if err != nil {
+			return err
+		}
+		draining = stats.Draining
+	}
+	if draining {
+		return ErrDraining
+	}
+	return nil
+}
+
+// Put inserts a job into the tube, unless the server is draining
+func (c *Client) Put(tubeName string, body []byte, pri uint32, delay, ttr time.Duration) (uint64, error) {
+	if err := c.CheckDraining(); err != nil {
+		return 0, err
+	}
+	return c.put(tubeName, body, pri, delay, ttr)
+}
+
+// put inserts a job into the tube, the DRAINING reply of a server gone into drain mode since the last stats gives ErrDraining
+func (c *Client) put(tubeName string, body []byte, pri uint32, delay, ttr time.Duration) (uint64, error) {
+	id, err := c.tube(tubeName).Put(body, pri, delay, ttr)
+	if cerr, ok := err.(beanstalk.ConnError); ok && cerr.Err == beanstalk.ErrDraining {
+		c.setDraining(true)
+		return 0, ErrDraining
+	}
+	return id, err
+}
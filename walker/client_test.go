@@ -0,0 +1,119 @@
+package walker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/beanstalktest"
+)
+
+// newTestClient returns a client connected to a fake server, both are closed at the end of the test
+func newTestClient(t *testing.T) (*beanstalktest.Server, *Client) {
+	t.Helper()
+
+	s := beanstalktest.NewServer()
+	c, err := Dial(s.Addr)
+	if err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		s.Close()
+	})
+	return s, c
+}
+
+// fixedClock returns a clock stopped at the time
+func fixedClock(now time.Time) func() time.Time {
+	return func() time.Time { return now }
+}
+
+func TestServerStats(t *testing.T) {
+	s, c := newTestClient(t)
+	s.Put("emails", []byte("a"), 10, 0, time.Minute)
+	s.Put("emails", []byte("b"), 2000, 0, time.Minute)
+	s.Put("sms", []byte("c"), 10, time.Hour, time.Minute)
+
+	stats, err := c.ServerStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.CurrentJobsReady != 2 || stats.CurrentJobsUrgent != 1 || stats.CurrentJobsDelayed != 1 {
+		t.Errorf("ready/urgent/delayed = %d/%d/%d, want 2/1/1", stats.CurrentJobsReady, stats.CurrentJobsUrgent, stats.CurrentJobsDelayed)
+	}
+	if stats.TotalJobs != 3 {
+		t.Errorf("total-jobs = %d, want 3", stats.TotalJobs)
+	}
+	if stats.Version != beanstalktest.Version {
+		t.Errorf("version = %q, want %q", stats.Version, beanstalktest.Version)
+	}
+	if stats.Raw["current-jobs-ready"] != "2" {
+		t.Errorf("raw current-jobs-ready = %q, want 2", stats.Raw["current-jobs-ready"])
+	}
+}
+
+func TestAllTubeStats(t *testing.T) {
+	s, c := newTestClient(t)
+	s.Put("emails", []byte("a"), 10, 0, time.Minute)
+	s.Put("sms", []byte("b"), 10, time.Hour, time.Minute)
+
+	all, err := c.AllTubeStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]*TubeStats{}
+	for _, stats := range all {
+		got[stats.Name] = stats
+	}
+	if len(got) != 3 || got["default"] == nil {
+		t.Fatalf("tubes = %v, want default, emails and sms", got)
+	}
+	if got["emails"].CurrentJobsReady != 1 || got["sms"].CurrentJobsDelayed != 1 {
+		t.Errorf("emails ready = %d, sms delayed = %d, want 1 and 1", got["emails"].CurrentJobsReady, got["sms"].CurrentJobsDelayed)
+	}
+}
+
+func TestTubeStatsPause(t *testing.T) {
+	s, c := newTestClient(t)
+	start := time.Unix(1500000000, 0)
+	s.SetClock(fixedClock(start))
+	s.Put("emails", []byte("a"), 10, 0, time.Minute)
+
+	if _, err := c.Pause("emails", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	s.SetClock(fixedClock(start.Add(20 * time.Second)))
+
+	stats, err := c.TubeStats("emails")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pause != time.Minute || stats.PauseTimeLeft != 40*time.Second {
+		t.Errorf("pause = %s, left %s, want 1m0s and 40s", stats.Pause, stats.PauseTimeLeft)
+	}
+}
+
+func TestJobStats(t *testing.T) {
+	s, c := newTestClient(t)
+	start := time.Unix(1500000000, 0)
+	s.SetClock(fixedClock(start))
+	id := s.Put("emails", []byte("a"), 42, time.Minute, 30*time.Second)
+
+	s.SetClock(fixedClock(start.Add(15 * time.Second)))
+
+	stats, err := c.JobStats(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := JobStats{ID: id, Tube: "emails", State: StateDelayed, Pri: 42, Age: 15 * time.Second, Delay: time.Minute, TTR: 30 * time.Second, TimeLeft: 45 * time.Second}
+	stats.Raw = nil
+	if !reflect.DeepEqual(*stats, want) {
+		t.Errorf("stats = %+v, want %+v", *stats, want)
+	}
+
+	if _, err := c.JobStats(id + 1); !IsNotFound(err) {
+		t.Errorf("stats of a missing job: err = %v, want NOT_FOUND", err)
+	}
+}
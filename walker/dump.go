@@ -0,0 +1,126 @@
+package walker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Record is a job as written to a dump file, one JSON object per line
+// Durations are in seconds and the body is base64 encoded by encoding/json
+type Record struct {
+	ID    uint64 `json:"id"`
+	Tube  string `json:"tube"`
+	State State  `json:"state"`
+	Pri   uint32 `json:"pri"`
+	// Delay is the delay left, zero unless the job is delayed
+	Delay    int64  `json:"delay"`
+	TTR      int64  `json:"ttr"`
+	Age      int64  `json:"age"`
+	Reserves int    `json:"reserves"`
+	Timeouts int    `json:"timeouts"`
+	Releases int    `json:"releases"`
+	Buries   int    `json:"buries"`
+	Kicks    int    `json:"kicks"`
+	Body     []byte `json:"body"`
+}
+
+// NewRecord returns the record of the job
+func NewRecord(job *Job, stats *JobStats) *Record {
+	r := &Record{
+		ID:       job.ID,
+		Tube:     stats.Tube,
+		State:    stats.State,
+		Pri:      stats.Pri,
+		TTR:      int64(stats.TTR / time.Second),
+		Age:      int64(stats.Age / time.Second),
+		Reserves: stats.Reserves,
+		Timeouts: stats.Timeouts,
+		Releases: stats.Releases,
+		Buries:   stats.Buries,
+		Kicks:    stats.Kicks,
+		Body:     job.Body,
+	}
+	if stats.State == StateDelayed {
+		r.Delay = int64(stats.TimeLeft / time.Second)
+	}
+	return r
+}
+
+// Dump writes the jobs of the states in the tube whose id is in the range, in id order
+func (c *Client) Dump(w io.Writer, tubeName string, states []State, r ScanRange) (*Result, error) {
+	res := &Result{Operation: "dumped", Tube: tubeName}
+	if len(states) == 1 {
+		res.State = states[0]
+	}
+
+	enc := json.NewEncoder(w)
+	err := c.Scan(tubeName, states, r, func(job *Job, stats *JobStats) error {
+		if err := enc.Encode(NewRecord(job, stats)); err != nil {
+			return err
+		}
+		res.Count++
+		res.IDs = append(res.IDs, job.ID)
+		return nil
+	})
+
+	return res, err
+}
+
+// ReadDump calls fn for every record of the dump, in the order they were written
+func ReadDump(r io.Reader, fn func(rec *Record) error) error {
+	dec := json.NewDecoder(r)
+	for {
+		rec := &Record{}
+		if err := dec.Decode(rec); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// buryHold is the delay buried records are put with, no worker can reserve them before they are buried again
+const buryHold = time.Hour
+
+// Restore puts the record into the tube, the tube of the record when empty, and returns the new job id
+// Priority, TTR and the delay left are preserved, buried records are buried again when bury is set, which needs
+// beanstalkd 1.12 or later, see CheckReserveJob
+// Drain mode isn't checked beforehand, see CheckDraining, the put fails with ErrDraining
+func (c *Client) Restore(rec *Record, tubeName string, bury bool) (uint64, error) {
+	if tubeName == "" {
+		tubeName = rec.Tube
+	}
+	rebury := bury && rec.State == StateBuried
+	if rebury {
+		if err := c.CheckReserveJob(); err != nil {
+			return 0, err
+		}
+	}
+
+	delay := time.Duration(rec.Delay) * time.Second
+	if rebury {
+		delay = buryHold
+	}
+	id, err := c.put(tubeName, rec.Body, rec.Pri, delay, time.Duration(rec.TTR)*time.Second)
+	if err != nil {
+		return 0, err
+	}
+	if !rebury {
+		return id, nil
+	}
+
+	// no held copy is left behind, the dedicated connection may have it reserved
+	if err := c.BuryJob(id, rec.Pri); err != nil {
+		if reply, derr := c.rawCommand("delete %d", id); derr != nil || reply != "DELETED" {
+			return 0, fmt.Errorf("%v, job #%d may be left delayed", err, id)
+		}
+		return 0, err
+	}
+	return id, nil
+}
@@ -0,0 +1,91 @@
+package walker
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDumpRestore(t *testing.T) {
+	s, c := newTestClient(t)
+	start := time.Unix(1500000000, 0)
+	s.SetClock(fixedClock(start))
+	s.Put("emails", []byte("ready"), 10, 0, time.Minute)
+	s.Put("emails", []byte("delayed"), 20, time.Hour, 2*time.Minute)
+	s.Put("emails", []byte("buried"), 30, 0, time.Minute)
+	s.Put("sms", []byte("other tube"), 10, 0, time.Minute)
+	if err := c.BuryJob(3, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	r, err := c.Dump(out, "emails", []State{StateReady, StateDelayed, StateBuried}, ScanRange{1, 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Count != 3 {
+		t.Fatalf("dumped %d jobs, want 3", r.Count)
+	}
+
+	restored := map[State]*JobStats{}
+	err = ReadDump(out, func(rec *Record) error {
+		id, err := c.Restore(rec, "copy", true)
+		if err != nil {
+			return err
+		}
+		stats, err := c.JobStats(id)
+		restored[rec.State] = stats
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := restored[StateReady]; stats.State != StateReady || stats.Pri != 10 {
+		t.Errorf("ready job restored %s with priority %d", stats.State, stats.Pri)
+	}
+	if stats := restored[StateDelayed]; stats.State != StateDelayed || stats.Delay != time.Hour || stats.TTR != 2*time.Minute {
+		t.Errorf("delayed job restored %s with delay %s and TTR %s", stats.State, stats.Delay, stats.TTR)
+	}
+	if stats := restored[StateBuried]; stats.State != StateBuried || stats.Pri != 30 || stats.Tube != "copy" {
+		t.Errorf("buried job restored %s in %s with priority %d", stats.State, stats.Tube, stats.Pri)
+	}
+}
+
+func TestRestoreBuryOldServer(t *testing.T) {
+	s, c := newTestClient(t)
+	s.SetVersion("1.10")
+
+	rec := &Record{Tube: "emails", State: StateBuried, Pri: 10, TTR: 60, Body: []byte("a")}
+	if _, err := c.Restore(rec, "", true); err != ErrNoReserveJob {
+		t.Fatalf("rebury on 1.10: err = %v, want ErrNoReserveJob", err)
+	}
+	if stats, _ := c.ServerStats(); stats.TotalJobs != 0 {
+		t.Errorf("%d jobs put, want none", stats.TotalJobs)
+	}
+
+	// restored ready without -bury
+	if _, err := c.Restore(rec, "", false); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"1.12", true},
+		{"1.13", true},
+		{"2.0", true},
+		{"1.10", false},
+		{"1.4.6", false},
+		{"1.12+8+g0d9e7b3", true},
+		{"devel", true},
+	}
+	for _, tt := range tests {
+		if got := versionAtLeast(tt.version, 1, 12); got != tt.want {
+			t.Errorf("versionAtLeast(%q, 1.12) = %v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package walker
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kr/beanstalk"
+)
+
+// reserveTimeout bounds the wait for a ready job while burying
+const reserveTimeout = time.Second
+
+// Delete deletes every job of the state in the tube
+func (c *Client) Delete(tubeName string, state State) (*Result, error) {
+	r := &Result{Operation: "deleted", Tube: tubeName, State: state}
+
+	for {
+		job, err := c.Peek(tubeName, state)
+		if err != nil {
+			// no more jobs with the state
+			if IsNotFound(err) {
+				return r, nil
+			}
+			return r, err
+		}
+		if err := c.Conn.Delete(job.ID); err != nil {
+			return r, err
+		}
+		r.Count++
+		r.IDs = append(r.IDs, job.ID)
+	}
+}
+
+// Kick moves up to n buried jobs of the tube into the ready queue, every buried job when n isn't positive
+// Delayed jobs are kicked instead when the tube has no buried job, every delayed job when n isn't positive
+func (c *Client) Kick(tubeName string, n int) (*Result, error) {
+	r := &Result{Operation: "kicked", Tube: tubeName}
+
+	if n <= 0 {
+		stats, err := c.TubeStats(tubeName)
+		if err != nil {
+			return r, err
+		}
+		n = stats.CurrentJobsBuried
+		if n == 0 {
+			n = stats.CurrentJobsDelayed
+		}
+	}
+
+	kicked, err := c.tube(tubeName).Kick(n)
+	r.Count = kicked
+
+	return r, err
+}
+
+// Bury reserves the ready jobs of the tube and buries them keeping their priority
+func (c *Client) Bury(tubeName string) (*Result, error) {
+	r := &Result{Operation: "buried", Tube: tubeName, State: StateReady}
+	tubeSet := beanstalk.NewTubeSet(c.Conn, tubeName)
+
+	for {
+		id, _, err := tubeSet.Reserve(reserveTimeout)
+		if err != nil {
+			// no more ready jobs
+			if cerr, ok := err.(beanstalk.ConnError); ok && cerr.Err == beanstalk.ErrTimeout {
+				return r, nil
+			}
+			return r, err
+		}
+		stats, err := c.JobStats(id)
+		if err != nil {
+			return r, err
+		}
+		if err := c.Conn.Bury(id, stats.Pri); err != nil {
+			return r, err
+		}
+		r.Count++
+		r.IDs = append(r.IDs, id)
+	}
+}
+
+// Move puts every job of the state into the destination tube and deletes the original
+// Priority, TTR and the remaining delay are preserved, moved buried jobs become ready
+func (c *Client) Move(tubeName string, state State, dest string) (*Result, error) {
+	r := &Result{Operation: "moved to " + dest, Tube: tubeName, State: state}
+
+	// the jobs put back would be peeked again endlessly
+	if err := checkMoveDest(tubeName, dest); err != nil {
+		return r, err
+	}
+	if err := c.CheckDraining(); err != nil {
+		return r, err
+	}
+
+	for {
+		job, err := c.Peek(tubeName, state)
+		if err != nil {
+			if IsNotFound(err) {
+				return r, nil
+			}
+			return r, err
+		}
+		stats, err := c.JobStats(job.ID)
+		if err != nil {
+			return r, err
+		}
+
+		id, err := c.moveJob(job, stats, dest)
+		if err != nil {
+			return r, err
+		}
+		r.Count++
+		r.IDs = append(r.IDs, id)
+	}
+}
+
+func checkMoveDest(tubeName, dest string) error {
+	if dest == tubeName {
+		return fmt.Errorf("cannot move the jobs of %s into the same tube", tubeName)
+	}
+	return nil
+}
+
+// moveJob puts the job into the destination tube and deletes the original, it returns the new id
+func (c *Client) moveJob(job *Job, stats *JobStats, dest string) (uint64, error) {
+	var delay time.Duration
+	if stats.State == StateDelayed {
+		delay = stats.TimeLeft
+	}
+	id, err := c.put(dest, job.Body, stats.Pri, delay, stats.TTR)
+	if err != nil {
+		return 0, err
+	}
+	return id, c.Conn.Delete(job.ID)
+}
+
+// Pause stops the tube from handing out jobs for the duration
+func (c *Client) Pause(tubeName string, d time.Duration) (*Result, error) {
+	r := &Result{Operation: "paused", Tube: tubeName, Duration: d}
+	if err := c.tube(tubeName).Pause(d); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// JobFilter accepts the jobs a conditional operation applies to
+type JobFilter func(job *Job, stats *JobStats) bool
+
+// Selection describes the jobs of a conditional operation
+type Selection struct {
+	Tube   string
+	States []State
+	// Range bounds the probed job ids, see Scan
+	Range ScanRange
+	// Filter accepts the jobs to operate on, every job of the states when nil
+	Filter JobFilter
+	// DryRun only counts the selected jobs
+	DryRun bool
+}
+
+type selectedJob struct {
+	job   *Job
+	stats *JobStats
+}
+
+// selectJobs scans the jobs of the selection, the jobs are collected before operating so the scan isn't disturbed
+func (c *Client) selectJobs(sel Selection) ([]selectedJob, error) {
+	jobs := []selectedJob{}
+	err := c.Scan(sel.Tube, sel.States, sel.Range, func(job *Job, stats *JobStats) error {
+		if sel.Filter == nil || sel.Filter(job, stats) {
+			jobs = append(jobs, selectedJob{job, stats})
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// newSelectionResult returns the result of the operation, with the state when a single one is selected
+func newSelectionResult(operation string, sel Selection) *Result {
+	r := &Result{Operation: operation, Tube: sel.Tube, DryRun: sel.DryRun}
+	if len(sel.States) == 1 {
+		r.State = sel.States[0]
+	}
+	return r
+}
+
+// DeleteWhere deletes the selected jobs
+func (c *Client) DeleteWhere(sel Selection) (*Result, error) {
+	r := newSelectionResult("deleted", sel)
+	jobs, err := c.selectJobs(sel)
+	if err != nil {
+		return r, err
+	}
+
+	for _, s := range jobs {
+		if !sel.DryRun {
+			if err := c.Conn.Delete(s.job.ID); err != nil {
+				// deleted in the meantime
+				if IsNotFound(err) {
+					continue
+				}
+				return r, err
+			}
+		}
+		r.Count++
+		r.IDs = append(r.IDs, s.job.ID)
+	}
+	return r, nil
+}
+
+// KickWhere kicks the selected jobs into the ready queue, the jobs keep their id
+// Only buried and delayed jobs can be kicked, they are selected when the selection has no state
+func (c *Client) KickWhere(sel Selection) (*Result, error) {
+	if len(sel.States) == 0 {
+		sel.States = []State{StateBuried, StateDelayed}
+	}
+	r := newSelectionResult("kicked", sel)
+	for _, s := range sel.States {
+		if s != StateBuried && s != StateDelayed {
+			return r, fmt.Errorf("cannot kick %s jobs", s)
+		}
+	}
+
+	jobs, err := c.selectJobs(sel)
+	if err != nil {
+		return r, err
+	}
+
+	for _, s := range jobs {
+		if !sel.DryRun {
+			if err := c.KickJob(s.job.ID); err != nil {
+				if IsNotFound(err) {
+					continue
+				}
+				return r, err
+			}
+		}
+		r.Count++
+		r.IDs = append(r.IDs, s.job.ID)
+	}
+	return r, nil
+}
+
+// MoveWhere moves the selected jobs into the destination tube, see Move
+func (c *Client) MoveWhere(sel Selection, dest string) (*Result, error) {
+	r := newSelectionResult("moved to "+dest, sel)
+	if err := checkMoveDest(sel.Tube, dest); err != nil {
+		return r, err
+	}
+	if !sel.DryRun {
+		if err := c.CheckDraining(); err != nil {
+			return r, err
+		}
+	}
+
+	jobs, err := c.selectJobs(sel)
+	if err != nil {
+		return r, err
+	}
+
+	for _, s := range jobs {
+		id := s.job.ID
+		if !sel.DryRun {
+			if id, err = c.moveJob(s.job, s.stats, dest); err != nil {
+				return r, err
+			}
+		}
+		r.Count++
+		r.IDs = append(r.IDs, id)
+	}
+	return r, nil
+}
@@ -0,0 +1,186 @@
+package walker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelete(t *testing.T) {
+	s, c := newTestClient(t)
+	for i := 0; i < 3; i++ {
+		s.Put("emails", []byte("ready"), 10, 0, time.Minute)
+	}
+	delayed := s.Put("emails", []byte("delayed"), 10, time.Hour, time.Minute)
+	s.Put("sms", []byte("other tube"), 10, 0, time.Minute)
+
+	r, err := c.Delete("emails", StateReady)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Count != 3 || len(r.IDs) != 3 {
+		t.Errorf("deleted %d jobs %v, want 3", r.Count, r.IDs)
+	}
+	if got := r.String(); got != "emails: 3 ready jobs deleted" {
+		t.Errorf("result = %q", got)
+	}
+
+	// the other states and tubes are left alone
+	if _, err := c.PeekJob(delayed); err != nil {
+		t.Errorf("delayed job: %v", err)
+	}
+	if stats, _ := c.TubeStats("sms"); stats.CurrentJobsReady != 1 {
+		t.Errorf("sms ready = %d, want 1", stats.CurrentJobsReady)
+	}
+
+	// nothing left to delete is not an error
+	if r, err := c.Delete("emails", StateReady); err != nil || r.Count != 0 {
+		t.Errorf("second delete = %d, %v, want 0 and no error", r.Count, err)
+	}
+}
+
+func TestBury(t *testing.T) {
+	s, c := newTestClient(t)
+	a := s.Put("emails", []byte("a"), 5, 0, time.Minute)
+	b := s.Put("emails", []byte("b"), 2000, 0, time.Minute)
+	s.Put("emails", []byte("delayed"), 10, time.Hour, time.Minute)
+
+	r, err := c.Bury("emails")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Count != 2 {
+		t.Fatalf("buried %d jobs, want 2", r.Count)
+	}
+
+	for id, pri := range map[uint64]uint32{a: 5, b: 2000} {
+		stats, err := c.JobStats(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.State != StateBuried || stats.Pri != pri {
+			t.Errorf("job %d: %s with priority %d, want buried with %d", id, stats.State, stats.Pri, pri)
+		}
+	}
+	if stats, _ := c.TubeStats("emails"); stats.CurrentJobsDelayed != 1 {
+		t.Errorf("delayed = %d, want 1", stats.CurrentJobsDelayed)
+	}
+}
+
+func TestKick(t *testing.T) {
+	s, c := newTestClient(t)
+	for i := 0; i < 3; i++ {
+		s.Put("emails", []byte("a"), 10, 0, time.Minute)
+	}
+	if _, err := c.Bury("emails"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := c.Kick("emails", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Count != 2 {
+		t.Errorf("kicked %d jobs, want 2", r.Count)
+	}
+
+	// every buried job left
+	if r, err = c.Kick("emails", 0); err != nil || r.Count != 1 {
+		t.Errorf("kick all = %d, %v, want 1", r.Count, err)
+	}
+	if stats, _ := c.TubeStats("emails"); stats.CurrentJobsReady != 3 || stats.CurrentJobsBuried != 0 {
+		t.Errorf("ready/buried = %d/%d, want 3/0", stats.CurrentJobsReady, stats.CurrentJobsBuried)
+	}
+}
+
+func TestKickDelayed(t *testing.T) {
+	s, c := newTestClient(t)
+	s.Put("emails", []byte("a"), 10, time.Hour, time.Minute)
+	s.Put("emails", []byte("b"), 10, time.Hour, time.Minute)
+
+	// delayed jobs are kicked when none is buried
+	r, err := c.Kick("emails", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Count != 1 {
+		t.Errorf("kicked %d jobs, want 1", r.Count)
+	}
+	if stats, _ := c.TubeStats("emails"); stats.CurrentJobsReady != 1 || stats.CurrentJobsDelayed != 1 {
+		t.Errorf("ready/delayed = %d/%d, want 1/1", stats.CurrentJobsReady, stats.CurrentJobsDelayed)
+	}
+
+	// every delayed job left
+	s.Put("emails", []byte("c"), 10, time.Hour, time.Minute)
+	if r, err = c.Kick("emails", 0); err != nil || r.Count != 2 {
+		t.Errorf("kick all = %d, %v, want 2", r.Count, err)
+	}
+}
+
+func TestMove(t *testing.T) {
+	s, c := newTestClient(t)
+	start := time.Unix(1500000000, 0)
+	s.SetClock(fixedClock(start))
+	s.Put("emails", []byte("a"), 5, 0, time.Minute)
+	s.Put("emails", []byte("b"), 2000, 0, 30*time.Second)
+	s.Put("emails", []byte("delayed"), 10, time.Hour, time.Minute)
+
+	r, err := c.Move("emails", StateReady, "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Count != 2 || len(r.IDs) != 2 {
+		t.Fatalf("moved %d jobs %v, want 2", r.Count, r.IDs)
+	}
+	if got := r.String(); got != "emails: 2 ready jobs moved to archive" {
+		t.Errorf("result = %q", got)
+	}
+
+	stats, err := c.JobStats(r.IDs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Tube != "archive" || stats.Pri != 2000 || stats.TTR != 30*time.Second {
+		t.Errorf("moved job: %s with priority %d and TTR %s, want archive, 2000 and 30s", stats.Tube, stats.Pri, stats.TTR)
+	}
+	if stats, _ := c.TubeStats("emails"); stats.CurrentJobsReady != 0 || stats.CurrentJobsDelayed != 1 {
+		t.Errorf("emails ready/delayed = %d/%d, want 0/1", stats.CurrentJobsReady, stats.CurrentJobsDelayed)
+	}
+
+	// the jobs put back into the tube would be moved endlessly
+	s.Put("emails", []byte("c"), 10, 0, time.Minute)
+	for _, state := range []State{StateReady, StateDelayed} {
+		if _, err := c.Move("emails", state, "emails"); err == nil {
+			t.Errorf("move of %s jobs into the same tube succeeded", state)
+		}
+	}
+	if _, err := c.MoveWhere(Selection{Tube: "emails", Range: ScanRange{From: 1, To: 10}}, "emails"); err == nil {
+		t.Error("conditional move into the same tube succeeded")
+	}
+	if stats, _ := c.TubeStats("emails"); stats.CurrentJobsReady != 1 || stats.TotalJobs != 4 {
+		t.Errorf("emails ready/total = %d/%d after the refused moves, want 1/4", stats.CurrentJobsReady, stats.TotalJobs)
+	}
+}
+
+func TestPutDraining(t *testing.T) {
+	s, c := newTestClient(t)
+
+	if _, err := c.Put("emails", []byte("a"), 10, 0, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// the server went into drain mode since the last stats
+	s.SetDraining(true)
+	if _, err := c.Put("emails", []byte("b"), 10, 0, time.Minute); err != ErrDraining {
+		t.Errorf("put into draining server: err = %v, want ErrDraining", err)
+	}
+	if err := c.CheckDraining(); err != ErrDraining {
+		t.Errorf("CheckDraining = %v, want ErrDraining", err)
+	}
+
+	s.SetDraining(false)
+	if _, err := c.ServerStats(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CheckDraining(); err != nil {
+		t.Errorf("CheckDraining after drain mode = %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package walker
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Sample holds the server stats and the stats of every tube polled at a time
+type Sample struct {
+	Time   time.Time
+	System *ServerStats
+	Tubes  []*TubeStats
+}
+
+// Sample polls the server stats and the stats of every tube
+func (c *Client) Sample() (*Sample, error) {
+	system, err := c.ServerStats()
+	if err != nil {
+		return nil, err
+	}
+	tubes, err := c.AllTubeStats()
+	if err != nil {
+		return nil, err
+	}
+	return &Sample{Time: time.Now(), System: system, Tubes: tubes}, nil
+}
+
+// sampleRecord is the JSON form of a sample, stats are kept as returned by the server
+type sampleRecord struct {
+	Time   time.Time           `json:"time"`
+	System map[string]string   `json:"system"`
+	Tubes  []map[string]string `json:"tubes"`
+}
+
+func (s *Sample) MarshalJSON() ([]byte, error) {
+	r := sampleRecord{Time: s.Time, System: s.System.Raw}
+	for _, t := range s.Tubes {
+		r.Tubes = append(r.Tubes, t.Raw)
+	}
+	return json.Marshal(r)
+}
+
+func (s *Sample) UnmarshalJSON(b []byte) error {
+	r := sampleRecord{}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+
+	s.Time = r.Time
+	s.System = &ServerStats{}
+	if err := parseStats(r.System, s.System); err != nil {
+		return err
+	}
+	s.Tubes = nil
+	for _, raw := range r.Tubes {
+		t := &TubeStats{}
+		if err := parseStats(raw, t); err != nil {
+			return err
+		}
+		s.Tubes = append(s.Tubes, t)
+	}
+	return nil
+}
+
+// ReadSamples returns the samples written one JSON object per line, in the order they were written
+func ReadSamples(r io.Reader) ([]*Sample, error) {
+	samples := []*Sample{}
+	dec := json.NewDecoder(r)
+	for {
+		s := &Sample{}
+		if err := dec.Decode(s); err != nil {
+			if err == io.EOF {
+				return samples, nil
+			}
+			return samples, err
+		}
+		samples = append(samples, s)
+	}
+}
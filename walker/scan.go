@@ -0,0 +1,136 @@
+package walker
+
+import "errors"
+
+// ErrStopScan can be returned by the scan callback to end the scan early without error
+var ErrStopScan = errors.New("scan stopped")
+
+// ScanRange is the range of job ids probed by Scan, both ends included
+type ScanRange struct {
+	From, To uint64
+}
+
+// LastIDs returns the range of the last n job ids created by the server, see TopID
+func (c *Client) LastIDs(n uint64) (ScanRange, error) {
+	top, err := c.TopID()
+	if err != nil {
+		return ScanRange{}, err
+	}
+
+	r := ScanRange{1, top}
+	if r.To > n {
+		r.From = r.To - n + 1
+	}
+	return r, nil
+}
+
+// topProbeGap is the number of missing ids in a row ending the probe of the ids above total-jobs
+const topProbeGap = 1000
+
+// TopID returns the highest job id known to the server
+// Job ids are sequential, but total-jobs only counts the jobs put since the server started while the jobs replayed
+// from the binlog keep their ids and the new ones follow them. The next ready, delayed and buried jobs of every
+// tube are peeked, when one of them or the current jobs count tells the binlog was replayed the ids above are
+// probed until topProbeGap of them in a row are missing.
+func (c *Client) TopID() (uint64, error) {
+	stats, err := c.ServerStats()
+	if err != nil {
+		return 0, err
+	}
+	top := uint64(stats.TotalJobs)
+
+	tubes, err := c.ListTubes()
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range tubes {
+		for _, state := range []State{StateReady, StateDelayed, StateBuried} {
+			job, err := c.Peek(name, state)
+			if err != nil {
+				if IsNotFound(err) {
+					continue
+				}
+				return 0, err
+			}
+			if job.ID > top {
+				top = job.ID
+			}
+		}
+	}
+
+	current := stats.CurrentJobsReady + stats.CurrentJobsReserved + stats.CurrentJobsDelayed + stats.CurrentJobsBuried
+	if top == uint64(stats.TotalJobs) && current <= stats.TotalJobs {
+		return top, nil
+	}
+	for id, missing := top+1, 0; missing < topProbeGap; id++ {
+		if _, err := c.JobStats(id); err != nil {
+			if !IsNotFound(err) {
+				return 0, err
+			}
+			missing++
+			continue
+		}
+		top, missing = id, 0
+	}
+	return top, nil
+}
+
+// Truncated reports whether the range leaves out older job ids
+func (r ScanRange) Truncated() bool {
+	return r.From > 1
+}
+
+// Scan calls fn for every job of the states in the tube whose id is in the range, in id order
+// beanstalkd cannot list jobs, so every id of the range is probed with stats-job
+func (c *Client) Scan(tubeName string, states []State, r ScanRange, fn func(job *Job, stats *JobStats) error) error {
+	wanted := map[State]bool{}
+	for _, s := range states {
+		wanted[s] = true
+	}
+
+	for id := r.From; id <= r.To && id != 0; id++ {
+		if c.ScanProgress != nil {
+			if err := c.ScanProgress(id); err != nil {
+				if err == ErrStopScan {
+					return nil
+				}
+				return err
+			}
+		}
+
+		stats, err := c.JobStats(id)
+		if err != nil {
+			// deleted or never existed
+			if IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if stats.Tube != tubeName || !wanted[stats.State] {
+			continue
+		}
+
+		job, err := c.PeekJob(id)
+		if err != nil {
+			// gone in the meantime
+			if IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		if err := fn(job, stats); err != nil {
+			if err == ErrStopScan {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteJob deletes the job of the id
+func (c *Client) DeleteJob(id uint64) error {
+	return c.Conn.Delete(id)
+}
@@ -0,0 +1,80 @@
+package walker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTopID(t *testing.T) {
+	s, c := newTestClient(t)
+	for i := 0; i < 5; i++ {
+		s.Put("emails", []byte("a"), 10, 0, time.Minute)
+	}
+
+	top, err := c.TopID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if top != 5 {
+		t.Errorf("top id = %d, want 5", top)
+	}
+	// total-jobs is trusted when nothing tells the binlog was replayed
+	if stats, _ := c.ServerStats(); stats.Raw["cmd-stats-job"] != "0" {
+		t.Errorf("%s job ids probed, want none", stats.Raw["cmd-stats-job"])
+	}
+
+	// the replayed jobs keep their ids, the next ready job is the oldest one
+	s.Restart()
+	s.Put("emails", []byte("b"), 10, 0, time.Minute)
+	if top, err = c.TopID(); err != nil {
+		t.Fatal(err)
+	}
+	if top != 6 {
+		t.Errorf("top id after a restart = %d, want 6", top)
+	}
+}
+
+func TestLastIDs(t *testing.T) {
+	s, c := newTestClient(t)
+	for i := 0; i < 10; i++ {
+		s.Put("emails", []byte("a"), 10, 0, time.Minute)
+	}
+
+	r, err := c.LastIDs(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r != (ScanRange{7, 10}) || !r.Truncated() {
+		t.Errorf("last 4 ids = %+v, truncated %v", r, r.Truncated())
+	}
+	if r, _ = c.LastIDs(100); r != (ScanRange{1, 10}) || r.Truncated() {
+		t.Errorf("last 100 ids = %+v, truncated %v", r, r.Truncated())
+	}
+}
+
+func TestScanProgress(t *testing.T) {
+	s, c := newTestClient(t)
+	for i := 0; i < 5; i++ {
+		s.Put("emails", []byte("a"), 10, 0, time.Minute)
+	}
+
+	probed := []uint64{}
+	c.ScanProgress = func(id uint64) error {
+		probed = append(probed, id)
+		if id == 3 {
+			return ErrStopScan
+		}
+		return nil
+	}
+	found := 0
+	err := c.Scan("emails", []State{StateReady}, ScanRange{1, 5}, func(job *Job, stats *JobStats) error {
+		found++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(probed) != 3 || found != 2 {
+		t.Errorf("probed %v and found %d jobs, want 1 to 3 and 2", probed, found)
+	}
+}
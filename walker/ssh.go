@@ -0,0 +1,131 @@
+package walker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// SSHConfig is the jump host the connections to beanstalkd are tunneled through
+type SSHConfig struct {
+	// Target is user@host[:port], the current user and port 22 by default
+	Target string
+	// KeyFiles are the private keys tried after the agent ones, the default keys of ~/.ssh when empty
+	KeyFiles []string
+	// KnownHosts checks the host key of the jump host, ~/.ssh/known_hosts when empty
+	KnownHosts string
+}
+
+// splitSSHTarget returns the user and the host:port of user@host[:port]
+func splitSSHTarget(target string) (string, string, error) {
+	name, host := "", target
+	if i := strings.LastIndex(target, "@"); i >= 0 {
+		name, host = target[:i], target[i+1:]
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("missing ssh host in %q", target)
+	}
+	if name == "" {
+		u, err := user.Current()
+		if err != nil {
+			return "", "", err
+		}
+		name = u.Username
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(unbracket(host), "22")
+	}
+	return name, host, nil
+}
+
+func sshDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ssh")
+}
+
+// authMethods returns the keys of the agent when given and of the key files, missing default keys are skipped
+func (s *SSHConfig) authMethods(keyAgent agent.Agent) ([]ssh.AuthMethod, error) {
+	methods := []ssh.AuthMethod{}
+	if keyAgent != nil {
+		methods = append(methods, ssh.PublicKeysCallback(keyAgent.Signers))
+	}
+
+	files, explicit := s.KeyFiles, true
+	if len(files) == 0 {
+		explicit = false
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			files = append(files, filepath.Join(sshDir(), name))
+		}
+	}
+	signers := []ssh.Signer{}
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			if explicit {
+				return nil, err
+			}
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(b)
+		if err != nil {
+			// passphrase protected keys are left to the agent
+			if _, ok := err.(*ssh.PassphraseMissingError); ok && !explicit {
+				continue
+			}
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		signers = append(signers, signer)
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no ssh agent nor private key to authenticate to %s", s.Target)
+	}
+	return methods, nil
+}
+
+// dial connects to the jump host, the host key must be known
+func (s *SSHConfig) dial(timeout time.Duration) (*ssh.Client, error) {
+	name, host, err := splitSSHTarget(s.Target)
+	if err != nil {
+		return nil, err
+	}
+	// the agent signs during the handshake only
+	var keyAgent agent.Agent
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			defer conn.Close()
+			keyAgent = agent.NewClient(conn)
+		}
+	}
+	methods, err := s.authMethods(keyAgent)
+	if err != nil {
+		return nil, err
+	}
+	knownHosts := s.KnownHosts
+	if knownHosts == "" {
+		knownHosts = filepath.Join(sshDir(), "known_hosts")
+	}
+	hostKey, err := knownhosts.New(knownHosts)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.Dial("tcp", host, &ssh.ClientConfig{
+		User:            name,
+		Auth:            methods,
+		HostKeyCallback: hostKey,
+		Timeout:         timeout,
+	})
+}
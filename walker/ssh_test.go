@@ -0,0 +1,243 @@
+package walker
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kadekcipta/beanwalker/beanstalktest"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshServer stands in for sshd, it authenticates a single key and forwards the direct-tcpip channels
+type sshServer struct {
+	Addr string
+	// hang leaves the forwarded connections unanswered
+	hang bool
+	ln   net.Listener
+}
+
+func newSSHServer(t *testing.T, clientKey ssh.PublicKey, hostKey ssh.Signer, hang bool) *sshServer {
+	t.Helper()
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &sshServer{Addr: ln.Addr().String(), hang: hang, ln: ln}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *sshServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for ch := range chans {
+		if ch.ChannelType() != "direct-tcpip" {
+			ch.Reject(ssh.UnknownChannelType, "direct-tcpip only")
+			continue
+		}
+		if s.hang {
+			continue
+		}
+		var target struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if err := ssh.Unmarshal(ch.ExtraData(), &target); err != nil {
+			ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		upstream, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+		if err != nil {
+			ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, chReqs, err := ch.Accept()
+		if err != nil {
+			upstream.Close()
+			continue
+		}
+		go ssh.DiscardRequests(chReqs)
+		go func() {
+			io.Copy(channel, upstream)
+			channel.Close()
+		}()
+		go func() {
+			io.Copy(upstream, channel)
+			upstream.Close()
+		}()
+	}
+}
+
+// sshFixture starts a jump host and returns its config, a client key file and a known_hosts file trusting it,
+// along with the client key
+func sshFixture(t *testing.T, hang bool) (*SSHConfig, ed25519.PrivateKey) {
+	t.Helper()
+	// keys of the developer's agent are kept out of the tests
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKey, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newSSHServer(t, clientKey, hostKey, hang)
+
+	dir := t.TempDir()
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.Addr)}, hostKey.PublicKey())
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return &SSHConfig{Target: "tester@" + s.Addr, KeyFiles: []string{keyFile}, KnownHosts: knownHosts}, clientPriv
+}
+
+func TestSSHTunnel(t *testing.T) {
+	config, _ := sshFixture(t, false)
+	bs := beanstalktest.NewServer()
+	defer bs.Close()
+	bs.Put("emails", []byte("a"), 10, 0, time.Minute)
+
+	d := &Dialer{SSH: config, Timeout: 5 * time.Second}
+	c, err := d.Dial(bs.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	defer d.tunnel.Close()
+
+	stats, err := c.TubeStats("emails")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.CurrentJobsReady != 1 {
+		t.Errorf("ready = %d through the tunnel, want 1", stats.CurrentJobsReady)
+	}
+}
+
+func TestSSHUnknownHost(t *testing.T) {
+	config, _ := sshFixture(t, false)
+	config.KnownHosts = filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(config.KnownHosts, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &Dialer{SSH: config, Timeout: 5 * time.Second}
+	if _, err := d.Dial("127.0.0.1:11300"); err == nil {
+		t.Fatal("dialed through a jump host missing from known_hosts")
+	}
+}
+
+func TestSSHTunnelTimeout(t *testing.T) {
+	config, _ := sshFixture(t, true)
+
+	d := &Dialer{SSH: config, Timeout: 200 * time.Millisecond}
+	start := time.Now()
+	if _, err := d.Dial("127.0.0.1:11300"); err == nil {
+		t.Fatal("dialed through a jump host never answering the forward")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("dial gave up after %s, want about the 200ms timeout", elapsed)
+	}
+}
+
+func TestSSHAgentClosed(t *testing.T) {
+	config, key := sshFixture(t, false)
+	bs := beanstalktest.NewServer()
+	defer bs.Close()
+
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	closed := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		agent.ServeAgent(keyring, conn)
+		close(closed)
+	}()
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	// without key files the agent key authenticates
+	config.KeyFiles = nil
+	t.Setenv("HOME", t.TempDir())
+	d := &Dialer{SSH: config, Timeout: 5 * time.Second}
+	c, err := d.Dial(bs.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	defer d.tunnel.Close()
+
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("the agent connection is still open after the handshake")
+	}
+}
@@ -0,0 +1,245 @@
+package walker
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ServerStats holds the parsed reply of the stats command
+type ServerStats struct {
+	CurrentJobsUrgent     int           `stat:"current-jobs-urgent"`
+	CurrentJobsReady      int           `stat:"current-jobs-ready"`
+	CurrentJobsReserved   int           `stat:"current-jobs-reserved"`
+	CurrentJobsDelayed    int           `stat:"current-jobs-delayed"`
+	CurrentJobsBuried     int           `stat:"current-jobs-buried"`
+	CmdPut                int           `stat:"cmd-put"`
+	CmdPeek               int           `stat:"cmd-peek"`
+	CmdPeekReady          int           `stat:"cmd-peek-ready"`
+	CmdPeekDelayed        int           `stat:"cmd-peek-delayed"`
+	CmdPeekBuried         int           `stat:"cmd-peek-buried"`
+	CmdReserve            int           `stat:"cmd-reserve"`
+	CmdReserveWithTimeout int           `stat:"cmd-reserve-with-timeout"`
+	CmdTouch              int           `stat:"cmd-touch"`
+	CmdUse                int           `stat:"cmd-use"`
+	CmdWatch              int           `stat:"cmd-watch"`
+	CmdIgnore             int           `stat:"cmd-ignore"`
+	CmdDelete             int           `stat:"cmd-delete"`
+	CmdRelease            int           `stat:"cmd-release"`
+	CmdBury               int           `stat:"cmd-bury"`
+	CmdKick               int           `stat:"cmd-kick"`
+	CmdStats              int           `stat:"cmd-stats"`
+	CmdStatsJob           int           `stat:"cmd-stats-job"`
+	CmdStatsTube          int           `stat:"cmd-stats-tube"`
+	CmdListTubes          int           `stat:"cmd-list-tubes"`
+	CmdListTubeUsed       int           `stat:"cmd-list-tube-used"`
+	CmdListTubesWatched   int           `stat:"cmd-list-tubes-watched"`
+	CmdPauseTube          int           `stat:"cmd-pause-tube"`
+	JobTimeouts           int           `stat:"job-timeouts"`
+	TotalJobs             int           `stat:"total-jobs"`
+	MaxJobSize            int           `stat:"max-job-size"`
+	CurrentTubes          int           `stat:"current-tubes"`
+	CurrentConnections    int           `stat:"current-connections"`
+	CurrentProducers      int           `stat:"current-producers"`
+	CurrentWorkers        int           `stat:"current-workers"`
+	CurrentWaiting        int           `stat:"current-waiting"`
+	TotalConnections      int           `stat:"total-connections"`
+	PID                   int           `stat:"pid"`
+	Version               string        `stat:"version"`
+	RusageUtime           float64       `stat:"rusage-utime"`
+	RusageStime           float64       `stat:"rusage-stime"`
+	Uptime                time.Duration `stat:"uptime"`
+	BinlogOldestIndex     int           `stat:"binlog-oldest-index"`
+	BinlogCurrentIndex    int           `stat:"binlog-current-index"`
+	BinlogRecordsMigrated int           `stat:"binlog-records-migrated"`
+	BinlogRecordsWritten  int           `stat:"binlog-records-written"`
+	BinlogMaxSize         int           `stat:"binlog-max-size"`
+	Draining              bool          `stat:"draining"`
+	ID                    string        `stat:"id"`
+	Hostname              string        `stat:"hostname"`
+	OS                    string        `stat:"os"`
+	Platform              string        `stat:"platform"`
+
+	// Raw keeps every stat as returned by the server, including the ones without field
+	Raw map[string]string `stat:"-"`
+}
+
+// TubeStats holds the parsed reply of the stats-tube command
+type TubeStats struct {
+	Name                string        `stat:"name"`
+	CurrentJobsUrgent   int           `stat:"current-jobs-urgent"`
+	CurrentJobsReady    int           `stat:"current-jobs-ready"`
+	CurrentJobsReserved int           `stat:"current-jobs-reserved"`
+	CurrentJobsDelayed  int           `stat:"current-jobs-delayed"`
+	CurrentJobsBuried   int           `stat:"current-jobs-buried"`
+	TotalJobs           int           `stat:"total-jobs"`
+	CurrentUsing        int           `stat:"current-using"`
+	CurrentWatching     int           `stat:"current-watching"`
+	CurrentWaiting      int           `stat:"current-waiting"`
+	CmdDelete           int           `stat:"cmd-delete"`
+	CmdPauseTube        int           `stat:"cmd-pause-tube"`
+	Pause               time.Duration `stat:"pause"`
+	PauseTimeLeft       time.Duration `stat:"pause-time-left"`
+
+	// Raw keeps every stat as returned by the server, including the ones without field
+	Raw map[string]string `stat:"-"`
+}
+
+// JobStats holds the parsed reply of the stats-job command
+type JobStats struct {
+	ID       uint64        `stat:"id"`
+	Tube     string        `stat:"tube"`
+	State    State         `stat:"state"`
+	Pri      uint32        `stat:"pri"`
+	Age      time.Duration `stat:"age"`
+	Delay    time.Duration `stat:"delay"`
+	TTR      time.Duration `stat:"ttr"`
+	TimeLeft time.Duration `stat:"time-left"`
+	File     int           `stat:"file"`
+	Reserves int           `stat:"reserves"`
+	Timeouts int           `stat:"timeouts"`
+	Releases int           `stat:"releases"`
+	Buries   int           `stat:"buries"`
+	Kicks    int           `stat:"kicks"`
+
+	// Raw keeps every stat as returned by the server, including the ones without field
+	Raw map[string]string `stat:"-"`
+}
+
+// StatError reports a stat value which cannot be parsed into its field
+type StatError struct {
+	Key   string
+	Value string
+	Err   error
+}
+
+func (e *StatError) Error() string {
+	return fmt.Sprintf("stat %s: invalid value %q: %v", e.Key, e.Value, e.Err)
+}
+
+// parseStats fills the tagged fields of the struct pointed by v, missing stats are left as zero value
+func parseStats(raw map[string]string, v interface{}) error {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		key := rt.Field(i).Tag.Get("stat")
+		if key == "" || key == "-" {
+			continue
+		}
+		value, ok := raw[key]
+		if !ok {
+			continue
+		}
+		if err := setField(rv.Field(i), value); err != nil {
+			return &StatError{key, value, err}
+		}
+	}
+
+	if f := rv.FieldByName("Raw"); f.IsValid() {
+		f.Set(reflect.ValueOf(raw))
+	}
+
+	return nil
+}
+
+// setField parses the value by the field type, durations are given in seconds
+func setField(f reflect.Value, value string) error {
+	if f.Type() == durationType {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(time.Duration(n) * time.Second))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+
+	case reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, f.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.SetUint(n)
+
+	default:
+		return fmt.Errorf("unsupported field type %s", f.Type())
+	}
+
+	return nil
+}
+
+// Field is a single stat with its parsed value
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Fields returns the stats of the struct pointed by v in declaration order, followed by the stats
+// without field sorted by name as string values
+func Fields(v interface{}) []Field {
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+
+	fields := []Field{}
+	known := map[string]bool{}
+	for i := 0; i < rt.NumField(); i++ {
+		key := rt.Field(i).Tag.Get("stat")
+		if key == "" || key == "-" {
+			continue
+		}
+		known[key] = true
+		fields = append(fields, Field{key, rv.Field(i).Interface()})
+	}
+
+	raw, _ := rv.FieldByName("Raw").Interface().(map[string]string)
+	unknown := []string{}
+	for k := range raw {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		fields = append(fields, Field{k, raw[k]})
+	}
+
+	return fields
+}
+
+// FieldMap returns the stats of the struct pointed by v keyed by name
+func FieldMap(v interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, f := range Fields(v) {
+		m[f.Key] = f.Value
+	}
+	return m
+}
@@ -0,0 +1,83 @@
+package walker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+)
+
+// TLSConfig is the TLS of the connections to beanstalkd fronted by a TLS terminating proxy, e.g. stunnel or haproxy
+type TLSConfig struct {
+	// CAFile is the PEM bundle of the certificate authorities trusted, the system ones when empty
+	CAFile string
+	// CertFile and KeyFile are the client certificate and its key, both or none
+	CertFile string
+	KeyFile  string
+	// ServerName is checked against the server certificate, the host of the address when empty
+	ServerName string
+	// InsecureSkipVerify accepts any server certificate, for labs only
+	InsecureSkipVerify bool
+}
+
+// clientConfig returns the TLS configuration of the connection to the address
+func (t *TLSConfig) clientConfig(a Addr) (*tls.Config, error) {
+	config := &tls.Config{ServerName: t.ServerName, InsecureSkipVerify: t.InsecureSkipVerify}
+
+	if config.ServerName == "" && a.Network == "tcp" {
+		host, _, err := net.SplitHostPort(a.Address)
+		if err != nil {
+			return nil, err
+		}
+		config.ServerName = host
+	}
+	if config.ServerName == "" && !config.InsecureSkipVerify {
+		return nil, fmt.Errorf("tls: missing server name of %s", a)
+	}
+
+	if t.CAFile != "" {
+		b, err := ioutil.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("tls: no certificate found in %s", t.CAFile)
+		}
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, fmt.Errorf("tls: the client certificate requires both the certificate and the key files")
+		}
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// handshake runs the TLS handshake over the connection, within the timeout when not zero
+func (t *TLSConfig) handshake(conn net.Conn, a Addr, timeout time.Duration) (net.Conn, error) {
+	config, err := t.clientConfig(a)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if timeout > 0 {
+		tlsConn.SetDeadline(time.Now().Add(timeout))
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
@@ -0,0 +1,191 @@
+package walker
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate signed by the parent, self-signed without parent
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(t *testing.T, name string, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.Subject = pkix.Name{CommonName: name}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert, key, der}
+}
+
+// writeFiles writes the certificate and its key as PEM files, it returns their paths
+func (c *testCert) writeFiles(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+
+	certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+"-key.pem")
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// tlsFixture starts a TLS server requiring a client certificate of the CA, it returns the address along with
+// the CA file and the client certificate and key files
+// The server certificate is valid for beanstalk.test and 127.0.0.1
+func tlsFixture(t *testing.T) (Addr, string, string, string) {
+	t.Helper()
+
+	ca := newTestCert(t, "test CA", &x509.Certificate{IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil)
+	server := newTestCert(t, "beanstalk.test", &x509.Certificate{
+		DNSNames:    []string{"beanstalk.test"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := newTestCert(t, "worker", &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, ca)
+
+	dir := t.TempDir()
+	caFile, _ := ca.writeFiles(t, dir, "ca")
+	certFile, keyFile := client.writeFiles(t, dir, "client")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{server.tlsCertificate()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		// the client certificate is checked before the client handshake ends
+		MaxVersion: tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}()
+		}
+	}()
+
+	return Addr{"tcp", ln.Addr().String()}, caFile, certFile, keyFile
+}
+
+func TestTLSHandshake(t *testing.T) {
+	addr, caFile, certFile, keyFile := tlsFixture(t)
+
+	tests := []struct {
+		name string
+		tls  TLSConfig
+		ok   bool
+	}{
+		{"ca and client certificate", TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, true},
+		{"server name", TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "beanstalk.test"}, true},
+		{"wrong server name", TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "other.test"}, false},
+		{"system authorities", TLSConfig{CertFile: certFile, KeyFile: keyFile}, false},
+		{"insecure", TLSConfig{CertFile: certFile, KeyFile: keyFile, InsecureSkipVerify: true}, true},
+		{"no client certificate", TLSConfig{CAFile: caFile}, false},
+		{"certificate without key", TLSConfig{CAFile: caFile, CertFile: certFile}, false},
+		{"ca without certificate", TLSConfig{CAFile: keyFile, CertFile: certFile, KeyFile: keyFile}, false},
+		{"missing ca", TLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}, false},
+	}
+	for _, tt := range tests {
+		d := &Dialer{TLS: &tt.tls, Timeout: 5 * time.Second}
+		conn, err := d.dialAddr(addr)
+		if err == nil {
+			conn.Close()
+		}
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want success %v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestTLSServerName(t *testing.T) {
+	// a socket has no host to check the certificate against
+	if _, err := (&TLSConfig{}).clientConfig(Addr{"unix", "/run/beanstalkd.sock"}); err == nil {
+		t.Error("TLS over a socket without server name succeeded")
+	}
+	config, err := (&TLSConfig{}).clientConfig(Addr{"tcp", "[::1]:11300"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ServerName != "::1" {
+		t.Errorf("server name = %q, want the host ::1", config.ServerName)
+	}
+}
+
+func TestTLSHandshakeTimeout(t *testing.T) {
+	// the server accepts the connections and never answers the handshake
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	d := &Dialer{TLS: &TLSConfig{InsecureSkipVerify: true}, Timeout: 200 * time.Millisecond}
+	start := time.Now()
+	if _, err := d.dialAddr(Addr{"tcp", ln.Addr().String()}); err == nil {
+		t.Fatal("handshake with a silent server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("handshake gave up after %s, want about the 200ms timeout", elapsed)
+	}
+}